@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/jessevdk/go-flags"
+	log "github.com/sirupsen/logrus"
+	"image"
+	"image/color"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"terrain/internal"
+	algo "terrain/internal/algorithms"
+	"terrain/internal/common"
+	"time"
+)
+
+var opts = struct {
+	Algorithms []string `short:"a" long:"algo" default-mask:"all" description:"Algorithm to run, may be repeated" choice:"bf" choice:"bf-parallel" choice:"dijkstra" choice:"dijkstra-parallel" choice:"dijkstra-distributed" choice:"dstar"`
+	Sizes      []int    `short:"s" long:"size" default:"25" default:"50" description:"Grid size, may be repeated"`
+	Repeat     int      `short:"r" long:"repeat" default:"3" description:"Repetitions of every run"`
+	Seed       int64    `long:"seed" default:"1" description:"Seed of the height maps, the same seed times the same terrain"`
+	Followers  int      `long:"followers" default:"2" description:"Number of in-process followers for the distributed runs"`
+	Follower   []string `long:"follower-url" description:"Use the remote follower instead of in-process ones, may be repeated"`
+	Profile    *string  `long:"profile" description:"Vehicle profile, the default costs if unset"`
+	CSV        *string  `long:"csv" description:"File path to store the records as CSV"`
+	JSON       *string  `long:"json" description:"File path to store the records as JSON"`
+	LaTeX      *string  `long:"latex" description:"File path to store the LaTeX tabular of mean wall times"`
+}{}
+
+var algorithms = []string{"bf", "bf-parallel", "dijkstra", "dijkstra-parallel", "dijkstra-distributed", "dstar"}
+
+// titles are the column names used in doc/content/compare.tex
+var titles = map[string]string{
+	"bf":                   "Классический BF",
+	"bf-parallel":          "Парал. однонодный BF",
+	"dijkstra":             "Классический Dijkstra",
+	"dijkstra-parallel":    "Парал. однонодный Dijkstra",
+	"dijkstra-distributed": "Парал. многонодный Dijkstra",
+	"dstar":                "D* Lite",
+}
+
+// Record is the result of a single run
+type Record struct {
+	Algorithm  string
+	Size       int
+	Seed       int64
+	Repeat     int
+	Wall       time.Duration
+	Network    time.Duration
+	Compute    time.Duration
+	Iterations int64
+	Relaxed    int64
+	// Allocated is the number of bytes allocated during the run
+	Allocated uint64
+	// PeakHeap is the largest heap size sampled during the run
+	PeakHeap uint64
+	Cost     float32
+}
+
+// startFollowers serves in-process followers on the loopback interface
+func startFollowers(count int) (urls []string, stop func()) {
+	servers := make([]*http.Server, count)
+	for idx := range servers {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			log.WithError(err).Panic("failed to listen")
+		}
+		servers[idx] = &http.Server{Handler: algo.NewFollower()}
+		go servers[idx].Serve(listener)
+		urls = append(urls, "http://"+listener.Addr().String())
+	}
+	return urls, func() {
+		for _, server := range servers {
+			server.Close()
+		}
+	}
+}
+
+// sampleHeap polls the heap size until stopped and returns the peak
+func sampleHeap() (stop func() uint64) {
+	var peak uint64
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var mem runtime.MemStats
+		for {
+			runtime.ReadMemStats(&mem)
+			if mem.HeapAlloc > peak {
+				peak = mem.HeapAlloc
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+	return func() uint64 {
+		close(done)
+		wg.Wait()
+		return peak
+	}
+}
+
+func run(name string, solver algo.Solver, field *algo.Field, size, repeat int) (record Record) {
+	from, to := common.Position{I: 0, J: 0}, common.Position{I: size - 1, J: size - 1}
+	stats := new(algo.Stats)
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	stopSampling := sampleHeap()
+	started := time.Now()
+	dists := solver(field, from, to, stats)
+	wall := time.Since(started)
+	peak := stopSampling()
+	runtime.ReadMemStats(&after)
+
+	return Record{
+		Algorithm:  name,
+		Size:       size,
+		Seed:       opts.Seed,
+		Repeat:     repeat,
+		Wall:       wall,
+		Network:    stats.Network,
+		Compute:    wall - stats.Network,
+		Iterations: stats.Iterations,
+		Relaxed:    stats.Relaxed,
+		Allocated:  after.TotalAlloc - before.TotalAlloc,
+		PeakHeap:   peak,
+		Cost:       dists.At(from.I, from.J),
+	}
+}
+
+// formatDuration mimics the hand written tables: 700ms, 28s, 14m 17s, 4h 50m
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < 10*time.Millisecond:
+		return fmt.Sprintf("%.2fms", float64(d.Microseconds())/1000)
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < 10*time.Second:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm %ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
+func writeLaTeX(filePath string, names []string, records []Record) {
+	sum, count := map[string]time.Duration{}, map[string]int{}
+	for _, record := range records {
+		key := fmt.Sprintf("%s/%d", record.Algorithm, record.Size)
+		sum[key] += record.Wall
+		count[key]++
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%% Generated by cmd/bench with --seed %d\n", opts.Seed))
+	b.WriteString("\\begin{tabular}{ | l" + strings.Repeat(" | l", len(names)) + " |}\n")
+	b.WriteString("    \\hline\n    Размер сетки")
+	for _, name := range names {
+		b.WriteString(" & " + titles[name])
+	}
+	b.WriteString(" \\\\ \\hline\n")
+	for _, size := range opts.Sizes {
+		b.WriteString(fmt.Sprintf("    %d$\\times$%d", size, size))
+		for _, name := range names {
+			key := fmt.Sprintf("%s/%d", name, size)
+			if count[key] == 0 {
+				b.WriteString(" & ---")
+				continue
+			}
+			b.WriteString(" & " + formatDuration(sum[key]/time.Duration(count[key])))
+		}
+		b.WriteString(" \\\\\n")
+	}
+	b.WriteString("    \\hline\n\\end{tabular}\n")
+
+	if err := os.WriteFile(filePath, []byte(b.String()), 0644); err != nil {
+		log.WithError(err).Panic("failed to write the LaTeX table")
+	}
+}
+
+func writeCSV(filePath string, records []Record) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		log.WithError(err).Panic("Failed to open the destination file")
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"algorithm", "size", "seed", "repeat", "wall_ns", "network_ns", "compute_ns",
+		"iterations", "relaxed", "allocated_bytes", "peak_heap_bytes", "cost"})
+	for _, r := range records {
+		writer.Write([]string{
+			r.Algorithm, strconv.Itoa(r.Size), strconv.FormatInt(r.Seed, 10), strconv.Itoa(r.Repeat),
+			strconv.FormatInt(int64(r.Wall), 10), strconv.FormatInt(int64(r.Network), 10),
+			strconv.FormatInt(int64(r.Compute), 10), strconv.FormatInt(r.Iterations, 10),
+			strconv.FormatInt(r.Relaxed, 10), strconv.FormatUint(r.Allocated, 10),
+			strconv.FormatUint(r.PeakHeap, 10), strconv.FormatFloat(float64(r.Cost), 'f', 2, 32),
+		})
+	}
+	writer.Flush()
+	if err = writer.Error(); err != nil {
+		log.WithError(err).Panic("failed to write the records")
+	}
+}
+
+func main() {
+	if _, err := flags.Parse(&opts); err != nil {
+		os.Exit(1)
+	}
+	names := opts.Algorithms
+	if len(names) == 0 {
+		names = algorithms
+	}
+
+	followers := opts.Follower
+	if len(followers) == 0 {
+		urls, stop := startFollowers(opts.Followers)
+		defer stop()
+		followers = urls
+	}
+	solvers := map[string]algo.Solver{
+		"bf":                   algo.BellmanFord,
+		"bf-parallel":          algo.ParallelBellmanFord,
+		"dijkstra":             algo.Dijkstra,
+		"dijkstra-parallel":    algo.ParallelDijkstra,
+		"dijkstra-distributed": algo.NewDistributedDijkstra(followers, &http.Client{}),
+		"dstar":                algo.SolveDStarLite,
+	}
+
+	records := make([]Record, 0)
+	for _, size := range opts.Sizes {
+		heights := internal.DefaultNoiseGenerator(opts.Seed).Generate(size, size)
+		rgba := image.NewRGBA(image.Rect(0, 0, size, size))
+		for i := 0; i < size; i++ {
+			for j := 0; j < size; j++ {
+				rgba.SetRGBA(i, j, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+			}
+		}
+		field := algo.NewField(heights, rgba)
+		if opts.Profile != nil {
+			field.SetProfile(algo.LoadProfile(*opts.Profile))
+		}
+
+		for _, name := range names {
+			for repeat := 0; repeat < opts.Repeat; repeat++ {
+				record := run(name, solvers[name], field, size, repeat)
+				fmt.Printf("%-22s %5dx%-5d #%d %12s relaxed %d, cost %0.2f\n",
+					name, size, size, repeat, formatDuration(record.Wall), record.Relaxed, record.Cost)
+				records = append(records, record)
+			}
+		}
+	}
+
+	if opts.CSV != nil {
+		writeCSV(*opts.CSV, records)
+	}
+	if opts.JSON != nil {
+		data, err := json.Marshal(records)
+		if err != nil {
+			log.WithError(err).Panic("failed to marshal the records")
+		}
+		if err = os.WriteFile(*opts.JSON, data, 0644); err != nil {
+			log.WithError(err).Panic("failed to write the records")
+		}
+	}
+	if opts.LaTeX != nil {
+		writeLaTeX(*opts.LaTeX, names, records)
+	}
+}
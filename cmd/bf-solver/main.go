@@ -13,13 +13,14 @@ import (
 )
 
 var opts = struct {
-	HeightMap string `short:"m" long:"height_map" required:"yes"`
-	Texture   string `short:"t" long:"texture" required:"yes"`
-	FromI     int    `long:"from-i" required:"yes"`
-	FromJ     int    `long:"from-j" required:"yes"`
-	ToI       int    `long:"to-i" required:"yes"`
-	ToJ       int    `long:"to-j" required:"yes"`
-	Out       string `short:"o" long:"out" required:"yes"`
+	HeightMap string  `short:"m" long:"height_map" required:"yes"`
+	Texture   string  `short:"t" long:"texture" required:"yes"`
+	Profile   *string `long:"profile" description:"Vehicle profile, the default costs if unset"`
+	FromI     int     `long:"from-i" required:"yes"`
+	FromJ     int     `long:"from-j" required:"yes"`
+	ToI       int     `long:"to-i" required:"yes"`
+	ToJ       int     `long:"to-j" required:"yes"`
+	Out       string  `short:"o" long:"out" required:"yes"`
 }{}
 
 func main() {
@@ -27,69 +28,24 @@ func main() {
 		os.Exit(1)
 	}
 
-	heights := internal.LoadHeightMap(opts.HeightMap)
+	heights := internal.LoadGrid(opts.HeightMap)
 	rgba := common.LoadRGBA(opts.Texture)
 
 	// Prepare types
 	field := algo.NewField(heights, rgba)
-	iMax, jMax := field.Bounds()
-	// Fill inf as -1
-	dists := internal.EmptyHeightMap(iMax, jMax)
-	for i := 0; i < iMax; i++ {
-		for j := 0; j < jMax; j++ {
-			dists.SetAt(i, j, float32(-1))
-		}
+	if opts.Profile != nil {
+		field.SetProfile(algo.LoadProfile(*opts.Profile))
 	}
-	dists.SetAt(opts.ToI, opts.ToJ, 0)
+	from, to := common.Position{I: opts.FromI, J: opts.FromJ}, common.Position{I: opts.ToI, J: opts.ToJ}
+	iMax, jMax := field.Bounds()
 
 	bar := pb.StartNew(iMax * jMax)
 	bar.SetTemplateString(`{{ bar . }} {{percent .}} {{ rtime .}} {{ etime . }}`)
-
-	stop := iMax*jMax - 2
-	for k := 0; k < stop; k++ {
-		bar.Increment()
-		for i := 0; i < iMax; i++ {
-			for j := 0; j < jMax; j++ {
-				dist := dists.At(i, j)
-				if dist < -0.5 {
-					continue
-				}
-				for dir := 0; dir < algo.DirectionCount; dir++ {
-					iDir, jDir := algo.DirectionToIndexes(i, j, algo.Direction(dir))
-					cost := field.Length(i, j, algo.Direction(dir))
-					if cost == nil {
-						continue
-					}
-					distDir := dists.At(iDir, jDir)
-					newDist := dist + *cost
-					if distDir < -0.5 || (distDir > -0.5 && newDist < distDir) {
-						dists.SetAt(iDir, jDir, newDist)
-					}
-				}
-			}
-		}
-	}
+	dists := algo.BellmanFord(field, from, to, &algo.Stats{Progress: func() { bar.Increment() }})
 	bar.Finish()
 	fmt.Printf("Total cost: %0.2f\n", dists.At(opts.FromI, opts.FromJ))
 
-	result := make([]common.Position, 0)
-	count := 0
-	for i, j := opts.FromI, opts.FromJ; i != opts.ToI && j != opts.ToJ; {
-		count++
-		result = append(result, common.Position{i, j})
-		minDist, minPosition := float32(-1), common.Position{}
-		for dir := 0; dir < algo.DirectionCount; dir++ {
-			iDir, jDir := algo.DirectionToIndexes(i, j, algo.Direction(dir))
-			if !field.IsValidIndex(iDir, jDir) {
-				continue
-			}
-			dist := dists.At(iDir, jDir)
-			if minDist < -0.5 || (minDist > -0.5 && dist > -0.5 && dist < minDist) {
-				minDist, minPosition = dist, common.Position{iDir, jDir}
-			}
-		}
-		i, j = minPosition.I, minPosition.J
-	}
+	result := algo.TracePath(field, dists, from, to)
 	file, err := os.Create(opts.Out)
 	if err != nil {
 		log.WithError(err).Panic("Failed to open the destination file")
@@ -102,5 +58,4 @@ func main() {
 		return
 	}
 	_, err = file.Write(data)
-
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jessevdk/go-flags"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"terrain/internal"
+	algo "terrain/internal/algorithms"
+	"terrain/internal/common"
+)
+
+var opts = struct {
+	HeightMap string            `short:"m" long:"height_map" required:"yes"`
+	Texture   string            `short:"t" long:"texture" required:"yes"`
+	Profile   *string           `long:"profile" description:"Vehicle profile, the default costs if unset"`
+	Bases     []common.Position `short:"b" long:"base" required:"yes" description:"Base position as i,j, may be repeated"`
+	Out       string            `short:"o" long:"out" required:"yes" description:"File path to store the partition PNG"`
+	Stats     *string           `long:"stats" description:"File path to store the per-base statistics as JSON"`
+}{}
+
+func main() {
+	if _, err := flags.Parse(&opts); err != nil {
+		os.Exit(1)
+	}
+
+	heights := internal.LoadGrid(opts.HeightMap)
+	rgba := common.LoadRGBA(opts.Texture)
+
+	field := algo.NewField(heights, rgba)
+	if opts.Profile != nil {
+		field.SetProfile(algo.LoadProfile(*opts.Profile))
+	}
+	catchment := algo.NewCatchment(field, opts.Bases...)
+	common.FlushRGBA(opts.Out, catchment.Image())
+
+	stats := catchment.Stats()
+	for _, stat := range stats {
+		fmt.Printf("Base (%d, %d): %d cells, mean cost %0.2f, max cost %0.2f\n",
+			stat.Base.I, stat.Base.J, stat.Area, stat.MeanCost, stat.MaxCost)
+	}
+	if opts.Stats != nil {
+		data, err := json.Marshal(stats)
+		if err != nil {
+			log.WithError(err).Panic("failed to marshal the statistics")
+		}
+		if err = os.WriteFile(*opts.Stats, data, 0644); err != nil {
+			log.WithError(err).Panic("failed to write the statistics")
+		}
+	}
+}
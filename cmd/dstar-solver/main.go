@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jessevdk/go-flags"
+	log "github.com/sirupsen/logrus"
+	"image/color"
+	"io"
+	"os"
+	"terrain/internal"
+	algo "terrain/internal/algorithms"
+	"terrain/internal/common"
+)
+
+var opts = struct {
+	HeightMap string  `short:"m" long:"height_map" required:"yes"`
+	Texture   string  `short:"t" long:"texture" required:"yes"`
+	Profile   *string `long:"profile" description:"Vehicle profile, the default costs if unset"`
+	FromI     int     `long:"from-i" required:"yes"`
+	FromJ     int     `long:"from-j" required:"yes"`
+	ToI       int     `long:"to-i" required:"yes"`
+	ToJ       int     `long:"to-j" required:"yes"`
+	Diffs     *string `short:"d" long:"diffs" default-mask:"STDIN" description:"Stream of JSON texture diffs"`
+	Out       string  `short:"o" long:"out" required:"yes" description:"File path to store the latest path"`
+}{}
+
+// diff is a single batch of changes discovered on the terrain. The path is
+// replanned after every diff
+type diff struct {
+	// Start is the new position of the robot, if it has moved
+	Start *common.Position
+	Cells []cell
+}
+
+type cell struct {
+	I, J   int
+	Color  *color.RGBA
+	Height *float32
+}
+
+func flushPath(path []common.Position) {
+	file, err := os.Create(opts.Out)
+	if err != nil {
+		log.WithError(err).Panic("Failed to open the destination file")
+	}
+	defer file.Close()
+	data, err := json.Marshal(path)
+	if err != nil {
+		log.WithError(err).Panic("failed to marshal the result")
+	}
+	if _, err = file.Write(data); err != nil {
+		log.WithError(err).Panic("failed to write the result")
+	}
+}
+
+func main() {
+	if _, err := flags.Parse(&opts); err != nil {
+		os.Exit(1)
+	}
+
+	var reader io.Reader = os.Stdin
+	if opts.Diffs != nil {
+		file, err := os.Open(*opts.Diffs)
+		if err != nil {
+			log.WithError(err).Error("Failed to open the diffs file")
+			os.Exit(2)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	heights := internal.LoadGrid(opts.HeightMap)
+	rgba := common.LoadRGBA(opts.Texture)
+
+	field := algo.NewField(heights, rgba)
+	if opts.Profile != nil {
+		field.SetProfile(algo.LoadProfile(*opts.Profile))
+	}
+	planner := algo.NewDStarLite(field,
+		common.Position{I: opts.FromI, J: opts.FromJ},
+		common.Position{I: opts.ToI, J: opts.ToJ},
+	)
+	planner.Replan()
+	fmt.Printf("Revision 0 total cost: %0.2f\n", planner.Cost())
+	flushPath(planner.Path())
+
+	decoder := json.NewDecoder(reader)
+	for revision := 1; ; revision++ {
+		change := new(diff)
+		if err := decoder.Decode(change); err == io.EOF {
+			break
+		} else if err != nil {
+			log.WithError(err).Panic("failed to decode the diff")
+		}
+
+		if change.Start != nil {
+			planner.MoveTo(*change.Start)
+		}
+		changed := make([]common.Position, 0, len(change.Cells))
+		for _, c := range change.Cells {
+			if c.Color != nil {
+				rgba.SetRGBA(c.I, c.J, *c.Color)
+			}
+			if c.Height != nil {
+				heights.SetAt(c.I, c.J, *c.Height)
+			}
+			changed = append(changed, common.Position{I: c.I, J: c.J})
+		}
+		planner.UpdateCells(changed...)
+		planner.Replan()
+		fmt.Printf("Revision %d total cost: %0.2f\n", revision, planner.Cost())
+		flushPath(planner.Path())
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jessevdk/go-flags"
+	log "github.com/sirupsen/logrus"
+	"math"
+	"os"
+	"terrain/internal"
+	algo "terrain/internal/algorithms"
+	"terrain/internal/common"
+)
+
+var opts = struct {
+	HeightMap string           `short:"m" long:"height_map" required:"yes"`
+	Texture   string           `short:"t" long:"texture" required:"yes"`
+	Profile   *string          `long:"profile" description:"Vehicle profile, the default costs if unset"`
+	Depots    int              `short:"k" long:"depots" default:"1" description:"Number of depots to place"`
+	Candidate common.HexColor  `long:"candidate-color" required:"yes" description:"Texture colour of the candidate sites"`
+	Demand    *common.HexColor `long:"demand-color" default-mask:"all passable cells" description:"Texture colour of the demand cells"`
+	MaxDemand int              `long:"max-demand" default:"10000" description:"Passable cells the default demand is thinned to on a regular lattice, every candidate keeps its costs to all of them"`
+	Objective string           `long:"objective" default:"max" choice:"max" choice:"mean"`
+	Out       string           `short:"o" long:"out" required:"yes" description:"File path to store the depots"`
+}{}
+
+// thin keeps the cells on the lattice sparse enough for at most about the
+// number of cells, the memory of the costs grows with the candidates times them
+func thin(cells []common.Position, max int) []common.Position {
+	if len(cells) <= max {
+		return cells
+	}
+	step := int(math.Ceil(math.Sqrt(float64(len(cells)) / float64(max))))
+	thinned := make([]common.Position, 0, len(cells)/(step*step)+1)
+	for _, cell := range cells {
+		if cell.I%step == 0 && cell.J%step == 0 {
+			thinned = append(thinned, cell)
+		}
+	}
+	return thinned
+}
+
+func main() {
+	if _, err := flags.Parse(&opts); err != nil {
+		os.Exit(1)
+	}
+	if opts.Depots < 0 {
+		log.Errorf("Incorrect number of depots %d", opts.Depots)
+		os.Exit(1)
+	}
+	if opts.MaxDemand <= 0 {
+		log.Errorf("Incorrect number of demand cells %d", opts.MaxDemand)
+		os.Exit(1)
+	}
+
+	heights := internal.LoadGrid(opts.HeightMap)
+	rgba := common.LoadRGBA(opts.Texture)
+
+	field := algo.NewField(heights, rgba)
+	if opts.Profile != nil {
+		field.SetProfile(algo.LoadProfile(*opts.Profile))
+	}
+	candidates := field.CellsWithColor(opts.Candidate)
+	var demand []common.Position
+	if opts.Demand != nil {
+		demand = field.CellsWithColor(*opts.Demand)
+	} else {
+		demand = thin(field.PassableCells(), opts.MaxDemand)
+	}
+	objective := algo.MaxCostObjective
+	if opts.Objective == "mean" {
+		objective = algo.MeanCostObjective
+	}
+	fmt.Printf("Placing %d depots among %d candidates for %d demand cells\n", opts.Depots, len(candidates), len(demand))
+
+	placement := algo.PlaceFacilities(field, candidates, demand, opts.Depots, objective)
+	fmt.Printf("Total cost: %0.2f, uncovered cells: %d\n", placement.Cost, placement.Uncovered)
+
+	data, err := json.Marshal(placement.Depots)
+	if err != nil {
+		log.WithError(err).Panic("failed to marshal the result")
+	}
+	if err = os.WriteFile(opts.Out, data, 0644); err != nil {
+		log.WithError(err).Panic("failed to write the result")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"github.com/jessevdk/go-flags"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"strings"
+	"terrain/internal"
+)
+
+var opts = struct {
+	Source      []string `short:"s" long:"src" required:"yes" description:"Height map in any supported format, a directory for the tiled one. Adjacent SRTM tiles are joined if repeated"`
+	Destination string   `short:"d" long:"dst" required:"yes" description:"File path to store the result, a directory for the tiled format"`
+	Format      string   `short:"f" long:"format" default:"binary" choice:"binary" choice:"json" choice:"tiled" choice:"png" description:"Format of the result"`
+	TileSize    int      `long:"tile-size" default:"1024" description:"Side of a tile in cells, tiled format only"`
+	DataType    string   `long:"dtype" default:"float32" choice:"float32" choice:"int16" description:"Type of the stored heights, binary format only"`
+	Gzip        bool     `long:"gzip" description:"Compress the heights, binary format only"`
+	PNGScale    float32  `long:"png-scale" description:"Height of a gray level of the PNG source or result, fitted to the range if unset when writing"`
+	PNGOffset   float32  `long:"png-offset" description:"Height of the black level of the PNG source or result"`
+	PNGNoData   *float32 `long:"png-no-data" description:"Height of the no data cells of the PNG source, they are black"`
+}{}
+
+func main() {
+	if _, err := flags.Parse(&opts); err != nil {
+		os.Exit(1)
+	}
+
+	var grid internal.Grid
+	switch source := opts.Source[0]; {
+	case len(opts.Source) > 1:
+		heights, err := internal.MosaicHGT(opts.Source...)
+		if err != nil {
+			log.WithError(err).Panic("failed to join the SRTM tiles")
+		}
+		grid = heights
+	case strings.EqualFold(filepath.Ext(source), ".png"):
+		options := internal.PNGOptions{Scale: opts.PNGScale, Offset: opts.PNGOffset, NoData: opts.PNGNoData}
+		if options.Scale == 0 {
+			options.Scale = 1
+		}
+		grid = internal.LoadPNGHeightMap(source, options)
+	default:
+		grid = internal.LoadGrid(source)
+	}
+
+	if opts.Format == "tiled" {
+		iMax, jMax := grid.Bounds()
+		tiled, err := internal.CreateTiledHeightMap(opts.Destination, iMax, jMax, opts.TileSize, grid.Spacing(), internal.DefaultTileCache)
+		if err != nil {
+			log.WithError(err).Panic("failed to create the tiled height map")
+		}
+		if heights, ok := grid.(*internal.HeightMap); ok {
+			if err = tiled.SetGeoreference(heights.Origin, heights.DegreeStep, heights.NoData); err != nil {
+				log.WithError(err).Panic("failed to write the height map")
+			}
+		}
+		internal.CopyGrid(tiled, grid, opts.TileSize)
+		if err = tiled.Flush(); err != nil {
+			log.WithError(err).Panic("failed to write the height map")
+		}
+		return
+	}
+
+	heights := internal.InMemory(grid)
+	file, err := os.Create(opts.Destination)
+	if err != nil {
+		log.WithError(err).Error("Failed to open the destination file")
+		os.Exit(2)
+	}
+	defer file.Close()
+
+	switch opts.Format {
+	case "json":
+		err = heights.Flush(file)
+	case "png":
+		var used internal.PNGOptions
+		used, err = heights.FlushPNG(file, internal.PNGOptions{Scale: opts.PNGScale, Offset: opts.PNGOffset})
+		if err == nil {
+			log.WithFields(used.Fields()).Info("Stored the PNG height map, read it back with the options")
+		}
+	default:
+		options := internal.BinaryOptions{}
+		if opts.DataType == "int16" {
+			options.DataType = internal.Int16Data
+		}
+		if opts.Gzip {
+			options.Compression = internal.GzipCompression
+		}
+		err = heights.FlushBinary(file, options)
+	}
+	if err != nil {
+		log.WithError(err).Panic("failed to write the height map")
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jessevdk/go-flags"
+	log "github.com/sirupsen/logrus"
+	"image"
+	"os"
+	"terrain/internal"
+	"terrain/internal/common"
+	"time"
+)
+
+// The options shared by all the subcommands
+var opts = struct {
+	Source             []string `short:"s" long:"src" required:"yes" description:"Height map in any supported format, repeated for stitch only"`
+	Destination        string   `short:"d" long:"dst" required:"yes" description:"File path to store the result"`
+	Format             string   `short:"f" long:"format" default:"binary" choice:"binary" choice:"json" choice:"png" description:"Format of the result"`
+	Texture            []string `short:"t" long:"texture" description:"Texture to transform the same way, one per source"`
+	TextureDestination *string  `long:"texture-dst" description:"File path to store the transformed texture"`
+	PNGScale           float32  `long:"png-scale" description:"Height of a gray level of the PNG result, fitted to the range if unset"`
+	PNGOffset          float32  `long:"png-offset" description:"Height of the black level of the PNG result"`
+}{}
+
+type cropCommand struct {
+	I      int `long:"i" required:"yes" description:"First cell along i"`
+	J      int `long:"j" required:"yes" description:"First cell along j"`
+	Width  int `long:"width" required:"yes" description:"Cells along i"`
+	Height int `long:"height" required:"yes" description:"Cells along j"`
+}
+
+type resampleCommand struct {
+	Width  int    `long:"width" required:"yes" description:"Cells along i"`
+	Height int    `long:"height" required:"yes" description:"Cells along j"`
+	Method string `long:"method" default:"bilinear" choice:"nearest" choice:"bilinear" choice:"bicubic" description:"Interpolation of the heights, the texture is always resampled to the nearest pixel"`
+}
+
+type stitchCommand struct {
+	Columns int `long:"columns" required:"yes" description:"Sources per row along i, the sources go row by row"`
+	Overlap int `long:"overlap" description:"Cells shared by the neighbour sources, 1 for the tiles repeating the edge"`
+}
+
+type scaleCommand struct {
+	Factor float32 `long:"factor" default:"1" description:"Height multiplier"`
+	Offset float32 `long:"offset" description:"Height added after the multiplication"`
+}
+
+type normaliseCommand struct {
+	Min float32 `long:"min" default:"0" description:"Height of the lowest cell"`
+	Max float32 `long:"max" default:"1" description:"Height of the highest cell"`
+}
+
+type flipCommand struct {
+	Axis string `long:"axis" default:"i" choice:"i" choice:"j" description:"Axis reversed, i swaps the east and the west"`
+}
+
+type upsampleCommand struct {
+	Factor    int     `long:"factor" default:"2" choice:"2" choice:"4" choice:"8" description:"Fine cells per coarse cell along an axis"`
+	Seed      *int64  `long:"seed" description:"Seed of the detail, random if unset"`
+	Hurst     float64 `long:"hurst" description:"Hurst exponent of the detail, estimated from the map if unset"`
+	Roughness float64 `long:"roughness" default:"1" description:"Amplitude multiplier of the detail, 0 interpolates only"`
+}
+
+type rotateCommand struct {
+	Quarters int `long:"quarters" default:"1" description:"Clockwise quarter turns as seen on the texture, negative turn counter-clockwise"`
+}
+
+func load() (heights *internal.HeightMap, rgba *image.RGBA, err error) {
+	if len(opts.Source) != 1 {
+		return nil, nil, fmt.Errorf("only stitch takes several sources")
+	}
+	heights = internal.InMemory(internal.LoadGrid(opts.Source[0]))
+	switch len(opts.Texture) {
+	case 0:
+	case 1:
+		rgba = common.LoadRGBA(opts.Texture[0])
+		iMax, jMax := heights.Bounds()
+		if rgba.Rect.Size().X < iMax || rgba.Rect.Size().Y < jMax {
+			return nil, nil, fmt.Errorf("the texture is smaller than the height map")
+		}
+	default:
+		return nil, nil, fmt.Errorf("only stitch takes several textures")
+	}
+	return
+}
+
+func store(heights *internal.HeightMap, rgba *image.RGBA) error {
+	// Nothing is written if the texture has nowhere to go
+	if rgba != nil && opts.TextureDestination == nil {
+		return fmt.Errorf("no --texture-dst for the texture")
+	}
+	file, err := os.Create(opts.Destination)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	switch opts.Format {
+	case "json":
+		err = heights.Flush(file)
+	case "png":
+		var used internal.PNGOptions
+		used, err = heights.FlushPNG(file, internal.PNGOptions{Scale: opts.PNGScale, Offset: opts.PNGOffset})
+		if err == nil {
+			log.WithFields(used.Fields()).Info("Stored the PNG height map, read it back with the options")
+		}
+	default:
+		err = heights.FlushBinary(file, internal.BinaryOptions{})
+	}
+	if err != nil {
+		return err
+	}
+	if rgba != nil {
+		common.FlushRGBA(*opts.TextureDestination, rgba)
+	}
+	return nil
+}
+
+// remap applies the transform of the cells to the source and its texture
+func remap(build func(iMax, jMax int) (internal.Remap, error)) error {
+	heights, rgba, err := load()
+	if err != nil {
+		return err
+	}
+	transform, err := build(heights.Bounds())
+	if err != nil {
+		return err
+	}
+	if rgba != nil {
+		rgba = transform.RGBA(rgba)
+	}
+	return store(transform.Heights(heights), rgba)
+}
+
+func (command *cropCommand) Execute([]string) error {
+	return remap(func(iMax, jMax int) (internal.Remap, error) {
+		return internal.CropRemap(iMax, jMax, command.I, command.J, command.Width, command.Height)
+	})
+}
+
+func (command *flipCommand) Execute([]string) error {
+	return remap(func(iMax, jMax int) (internal.Remap, error) {
+		return internal.FlipRemap(iMax, jMax, command.Axis == "i"), nil
+	})
+}
+
+func (command *rotateCommand) Execute([]string) error {
+	return remap(func(iMax, jMax int) (internal.Remap, error) {
+		return internal.RotateRemap(iMax, jMax, command.Quarters), nil
+	})
+}
+
+func (command *resampleCommand) Execute([]string) error {
+	heights, rgba, err := load()
+	if err != nil {
+		return err
+	}
+	iMax, jMax := heights.Bounds()
+	if rgba != nil {
+		rgba = internal.NearestRemap(iMax, jMax, command.Width, command.Height).RGBA(rgba)
+	}
+	if heights, err = internal.Resample(heights, command.Width, command.Height, internal.ResampleMethod(command.Method)); err != nil {
+		return err
+	}
+	return store(heights, rgba)
+}
+
+func (command *upsampleCommand) Execute([]string) error {
+	heights, rgba, err := load()
+	if err != nil {
+		return err
+	}
+	seed := time.Now().UnixMilli()
+	if command.Seed != nil {
+		seed = *command.Seed
+	}
+	sr := internal.DefaultSuperResolution(command.Factor, seed)
+	sr.Hurst, sr.Roughness = command.Hurst, command.Roughness
+	if err = sr.Validate(); err != nil {
+		return err
+	}
+	log.WithField("seed", seed).Info("Upsampling the height map")
+	if rgba != nil {
+		rgba = sr.UpsampleRemap(heights.Bounds()).RGBA(rgba)
+	}
+	return store(sr.Upsample(heights), rgba)
+}
+
+func (command *stitchCommand) Execute([]string) error {
+	if len(opts.Texture) != 0 && len(opts.Texture) != len(opts.Source) {
+		return fmt.Errorf("%d textures for %d sources", len(opts.Texture), len(opts.Source))
+	}
+	pieces := make([]*internal.HeightMap, len(opts.Source))
+	for idx, source := range opts.Source {
+		pieces[idx] = internal.InMemory(internal.LoadGrid(source))
+	}
+	heights, err := internal.Stitch(pieces, command.Columns, command.Overlap)
+	if err != nil {
+		return err
+	}
+	var rgba *image.RGBA
+	if len(opts.Texture) != 0 {
+		textures := make([]*image.RGBA, len(opts.Texture))
+		for idx, texture := range opts.Texture {
+			// The textures may be larger than the maps, the stitch takes the map part only
+			iMax, jMax := pieces[idx].Bounds()
+			textures[idx] = common.LoadRGBA(texture).SubImage(image.Rect(0, 0, iMax, jMax)).(*image.RGBA)
+		}
+		if rgba, err = internal.StitchRGBA(textures, command.Columns, command.Overlap); err != nil {
+			return err
+		}
+	}
+	return store(heights, rgba)
+}
+
+func (command *scaleCommand) Execute([]string) error {
+	heights, rgba, err := load()
+	if err != nil {
+		return err
+	}
+	heights.Rescale(command.Factor, command.Offset)
+	return store(heights, rgba)
+}
+
+func (command *normaliseCommand) Execute([]string) error {
+	heights, rgba, err := load()
+	if err != nil {
+		return err
+	}
+	heights.Normalise(command.Min, command.Max)
+	return store(heights, rgba)
+}
+
+func main() {
+	parser := flags.NewParser(&opts, flags.Default)
+	commands := []struct {
+		name, description string
+		data              interface{}
+	}{
+		{"crop", "Cut a window of the map", &cropCommand{}},
+		{"resample", "Change the resolution", &resampleCommand{}},
+		{"stitch", "Join several maps into a mosaic", &stitchCommand{}},
+		{"scale", "Multiply the heights and add an offset", &scaleCommand{}},
+		{"normalise", "Stretch the heights onto a range", &normaliseCommand{}},
+		{"upsample", "Raise the resolution adding the fractal detail, the coarse cells keep their heights", &upsampleCommand{}},
+		{"flip", "Mirror the map", &flipCommand{}},
+		{"rotate", "Turn the map by quarter turns", &rotateCommand{}},
+	}
+	for _, command := range commands {
+		if _, err := parser.AddCommand(command.name, command.description, command.description, command.data); err != nil {
+			log.WithError(err).Panic("failed to add the command")
+		}
+	}
+	// The parser prints the errors of the commands too
+	if _, err := parser.Parse(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jessevdk/go-flags"
+	log "github.com/sirupsen/logrus"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"terrain/internal"
+	"terrain/internal/common"
+)
+
+var opts = struct {
+	HeightMap      string          `short:"m" long:"height_map" required:"yes"`
+	Texture        *string         `short:"t" long:"texture" description:"Texture to paint the water over, a white one if unset"`
+	Out            string          `short:"o" long:"out" required:"yes" description:"File path to store the painted texture PNG"`
+	RiverThreshold float32         `long:"river-threshold" default:"500" description:"Number of upstream cells a river starts from"`
+	LakeDepth      float32         `long:"lake-depth" default:"0.1" description:"Depth of water a lake must hold, no lakes if 0"`
+	MinLakeArea    int             `long:"min-lake-area" default:"4" description:"Number of cells a lake must cover"`
+	RiverColor     common.HexColor `long:"river-color" default:"#0000ff" description:"Colour of the rivers, the water class of the default and the bundled profiles is impassable and #00ffff marsh is slow"`
+	LakeColor      common.HexColor `long:"lake-color" default:"#0000ff" description:"Colour of the lakes"`
+	Accumulation   *string         `long:"accumulation" description:"File path to store the flow accumulation as a binary height map"`
+	Filled         *string         `long:"filled" description:"File path to store the height map with the depressions filled as a binary one"`
+}{}
+
+func flushBinary(filePath string, heights *internal.HeightMap) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		log.WithError(err).Panic("failed to open the destination file")
+	}
+	defer file.Close()
+	if err = heights.FlushBinary(file, internal.BinaryOptions{}); err != nil {
+		log.WithError(err).Panic("failed to write the height map")
+	}
+}
+
+func main() {
+	if _, err := flags.Parse(&opts); err != nil {
+		os.Exit(1)
+	}
+
+	heights := internal.InMemory(internal.LoadGrid(opts.HeightMap))
+	iMax, jMax := heights.Bounds()
+	var rgba *image.RGBA
+	if opts.Texture != nil {
+		rgba = common.LoadRGBA(*opts.Texture)
+		if rgba.Rect.Size().X < iMax || rgba.Rect.Size().Y < jMax {
+			log.Panic("Incorrect sizes")
+		}
+	} else {
+		rgba = image.NewRGBA(image.Rect(0, 0, iMax, jMax))
+		draw.Draw(rgba, rgba.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	}
+
+	hydrology := internal.NewHydrology(heights, internal.HydrologyOptions{
+		RiverThreshold: opts.RiverThreshold,
+		LakeDepth:      opts.LakeDepth,
+		MinLakeArea:    opts.MinLakeArea,
+	})
+	hydrology.Paint(rgba, opts.RiverColor.RGBA(), opts.LakeColor.RGBA())
+	common.FlushRGBA(opts.Out, rgba)
+
+	rivers, lakes := 0, 0
+	for _, water := range hydrology.Mask {
+		switch water {
+		case internal.RiverCell:
+			rivers++
+		case internal.LakeCell:
+			lakes++
+		}
+	}
+	fmt.Printf("Rivers: %d cells, lakes: %d cells\n", rivers, lakes)
+
+	if opts.Accumulation != nil {
+		flushBinary(*opts.Accumulation, hydrology.Accumulation)
+	}
+	if opts.Filled != nil {
+		flushBinary(*opts.Filled, hydrology.Filled)
+	}
+}
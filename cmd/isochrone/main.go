@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/jessevdk/go-flags"
+	"os"
+	"terrain/internal"
+	algo "terrain/internal/algorithms"
+	"terrain/internal/common"
+)
+
+var opts = struct {
+	HeightMap string    `short:"m" long:"height_map" required:"yes"`
+	Texture   string    `short:"t" long:"texture" required:"yes"`
+	Profile   *string   `long:"profile" description:"Vehicle profile, the default costs if unset"`
+	FromI     int       `long:"from-i" required:"yes"`
+	FromJ     int       `long:"from-j" required:"yes"`
+	Budgets   []float32 `short:"b" long:"budget" required:"yes" description:"Cost budget, may be repeated"`
+	Overlay   *string   `long:"png" description:"File path to store the coloured texture"`
+	GeoJSON   *string   `long:"geojson" description:"File path to store the reachable polygons, in degrees or the projected coordinates of the height map, in cells if it has no georeference"`
+}{}
+
+func main() {
+	if _, err := flags.Parse(&opts); err != nil {
+		os.Exit(1)
+	}
+
+	heights := internal.LoadGrid(opts.HeightMap)
+	rgba := common.LoadRGBA(opts.Texture)
+
+	field := algo.NewField(heights, rgba)
+	if opts.Profile != nil {
+		field.SetProfile(algo.LoadProfile(*opts.Profile))
+	}
+	dists := algo.NewCostField(field, algo.Outbound, common.Position{I: opts.FromI, J: opts.FromJ})
+
+	if opts.Overlay != nil {
+		common.FlushRGBA(*opts.Overlay, algo.IsochroneOverlay(rgba, dists, opts.Budgets))
+	}
+	if opts.GeoJSON != nil {
+		dists.CellSize = heights.Spacing()
+		switch hm := heights.(type) {
+		case *internal.HeightMap:
+			dists.Origin, dists.DegreeStep = hm.Origin, hm.DegreeStep
+		case *internal.TiledHeightMap:
+			dists.Origin, dists.DegreeStep = hm.Georeference()
+		}
+		collection := common.NewGeoJSON()
+		for _, isochrone := range algo.NewIsochrones(dists, opts.Budgets) {
+			polygons := make([]algo.Polygon, len(isochrone.Polygons))
+			for k, polygon := range isochrone.Polygons {
+				polygons[k] = polygon.Georeference(dists)
+			}
+			collection.Add("MultiPolygon", polygons, map[string]interface{}{
+				"budget": isochrone.Budget,
+			})
+		}
+		collection.FlushToFile(*opts.GeoJSON)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jessevdk/go-flags"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"terrain/internal"
+	algo "terrain/internal/algorithms"
+	"terrain/internal/common"
+)
+
+var opts = struct {
+	HeightMap string  `short:"m" long:"height_map" required:"yes"`
+	Texture   string  `short:"t" long:"texture" required:"yes"`
+	Profile   *string `long:"profile" description:"Vehicle profile, the default costs if unset"`
+	Path      string  `short:"p" long:"path" required:"yes"`
+	Tolerance float32 `long:"tolerance" default:"0.05" description:"Relative cost increase allowed for the smoothed path over the original one"`
+	Epsilon   float64 `long:"epsilon" default:"0" description:"Douglas-Peucker distance in cells, 0 disables it"`
+	Spline    bool    `long:"spline" description:"Fit a Catmull-Rom spline through the waypoints"`
+	Out       string  `short:"o" long:"out" required:"yes" description:"File path to store the smoothed grid path"`
+	Waypoints *string `long:"waypoints" description:"File path to store the smoothed polyline"`
+}{}
+
+func writeJSON(filePath string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.WithError(err).Panic("failed to marshal the result")
+	}
+	if err = os.WriteFile(filePath, data, 0644); err != nil {
+		log.WithError(err).Panic("failed to write the result")
+	}
+}
+
+func main() {
+	if _, err := flags.Parse(&opts); err != nil {
+		os.Exit(1)
+	}
+
+	heights := internal.LoadGrid(opts.HeightMap)
+	rgba := common.LoadRGBA(opts.Texture)
+	path := make([]common.Position, 0)
+	data, err := os.ReadFile(opts.Path)
+	if err != nil {
+		log.WithError(err).Panic("failed to read path file")
+	}
+	if err = json.Unmarshal(data, &path); err != nil {
+		log.WithError(err).Panic("failed to unmarshal path file")
+	}
+
+	field := algo.NewField(heights, rgba)
+	if opts.Profile != nil {
+		field.SetProfile(algo.LoadProfile(*opts.Profile))
+	}
+	if cost, ok := field.PathCost(path); ok {
+		fmt.Printf("Original cost: %0.2f, %d cells\n", cost, len(path))
+	} else {
+		fmt.Printf("Original path has illegal moves, %d cells\n", len(path))
+	}
+	smoothed := algo.SmoothPath(field, path, algo.SmoothOptions{
+		Tolerance: opts.Tolerance,
+		Epsilon:   opts.Epsilon,
+		Spline:    opts.Spline,
+	})
+	fmt.Printf("Smoothed cost: %0.2f, %d cells, %d waypoints\n", smoothed.Cost, len(smoothed.Cells), len(smoothed.Waypoints))
+
+	writeJSON(opts.Out, smoothed.Cells)
+	if opts.Waypoints != nil {
+		writeJSON(*opts.Waypoints, smoothed.Waypoints)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jessevdk/go-flags"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"terrain/internal"
+	algo "terrain/internal/algorithms"
+	"terrain/internal/common"
+)
+
+var opts = struct {
+	HeightMap string  `short:"m" long:"height_map" required:"yes"`
+	Texture   string  `short:"t" long:"texture" required:"yes"`
+	Profile   *string `long:"profile" description:"Vehicle profile, the default costs if unset"`
+	Path      string  `short:"p" long:"path" required:"yes"`
+}{}
+
+func main() {
+	if _, err := flags.Parse(&opts); err != nil {
+		os.Exit(1)
+	}
+
+	heights := internal.LoadGrid(opts.HeightMap)
+	rgba := common.LoadRGBA(opts.Texture)
+	path := make([]common.Position, 0)
+	data, err := os.ReadFile(opts.Path)
+	if err != nil {
+		log.WithError(err).Panic("failed to read path file")
+	}
+	if err = json.Unmarshal(data, &path); err != nil {
+		log.WithError(err).Panic("failed to unmarshal path file")
+	}
+
+	field := algo.NewField(heights, rgba)
+	if opts.Profile != nil {
+		field.SetProfile(algo.LoadProfile(*opts.Profile))
+	}
+	cost, violation := field.ValidatePath(path)
+	if violation != nil {
+		fmt.Printf("Invalid path: %s\n", violation)
+		fmt.Printf("Cost up to the violation: %0.2f\n", cost)
+		os.Exit(2)
+	}
+	fmt.Printf("Valid path of %d cells\n", len(path))
+	fmt.Printf("Total cost: %0.2f\n", cost)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jessevdk/go-flags"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"strings"
+	"terrain/internal"
+	"terrain/internal/analysis"
+	"terrain/internal/common"
+)
+
+var opts = struct {
+	HeightMap        string  `short:"m" long:"height_map" required:"yes"`
+	Slope            *string `long:"slope" description:"File path to store the slope in degrees"`
+	Aspect           *string `long:"aspect" description:"File path to store the aspect in degrees clockwise from the north, -1 if flat"`
+	PlanCurvature    *string `long:"plan-curvature" description:"File path to store the plan curvature"`
+	ProfileCurvature *string `long:"profile-curvature" description:"File path to store the profile curvature"`
+	Ruggedness       *string `long:"ruggedness" description:"File path to store the terrain ruggedness index"`
+}{}
+
+// flush writes the colourised raster into a .png path and the binary float raster into any other one
+func flush(filePath string, kind analysis.Kind, raster *internal.HeightMap) {
+	if strings.EqualFold(filepath.Ext(filePath), ".png") {
+		common.FlushRGBA(filePath, analysis.Colorize(raster, analysis.DefaultRamp(kind, raster)))
+		return
+	}
+	file, err := os.Create(filePath)
+	if err != nil {
+		log.WithError(err).Panic("failed to open the destination file")
+	}
+	defer file.Close()
+	if err = raster.FlushBinary(file, internal.BinaryOptions{}); err != nil {
+		log.WithError(err).Panic("failed to write the raster")
+	}
+}
+
+func main() {
+	if _, err := flags.Parse(&opts); err != nil {
+		os.Exit(1)
+	}
+
+	outputs := map[analysis.Kind]*string{
+		analysis.SlopeKind:            opts.Slope,
+		analysis.AspectKind:           opts.Aspect,
+		analysis.PlanCurvatureKind:    opts.PlanCurvature,
+		analysis.ProfileCurvatureKind: opts.ProfileCurvature,
+		analysis.RuggednessKind:       opts.Ruggedness,
+	}
+	heights := internal.LoadGrid(opts.HeightMap)
+	written := false
+	for _, kind := range analysis.Kinds {
+		if outputs[kind] == nil {
+			continue
+		}
+		raster := analysis.Compute(heights, kind)
+		flush(*outputs[kind], kind, raster)
+		written = true
+	}
+	if !written {
+		fmt.Println("Nothing to compute, give at least one of the rasters")
+		os.Exit(1)
+	}
+}
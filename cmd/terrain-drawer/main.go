@@ -20,7 +20,7 @@ func main() {
 	window, terminate := internal.NewWindow(800, 600)
 	defer terminate()
 
-	heights := internal.LoadHeightMap(opts.HeightMap)
+	heights := internal.LoadGrid(opts.HeightMap)
 	texture := gl2.NewTextureFromFile(opts.Texture)
 
 	terrain := internal.NewTerrain2(heights, texture)
@@ -1,17 +1,102 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	flags "github.com/jessevdk/go-flags"
 	log "github.com/sirupsen/logrus"
 	"io"
 	"os"
 	"terrain/internal"
+	"terrain/internal/common"
+	"terrain/internal/texture"
+	"time"
 )
 
 var opts struct {
 	Destination *string `long:"dst" default-mask:"STDOUT" description:"File path to store the result"`
 	Height      int     `short:"h" long:"height" default:"1000" description:"The height map height"`
 	Width       int     `short:"w" long:"width" default:"1000" description:"The height map width"`
+	Format      string  `short:"f" long:"format" default:"json" choice:"json" choice:"binary" choice:"png" description:"Format of the result"`
+	PNGScale    float32 `long:"png-scale" description:"Height of a gray level, fitted to the range if unset"`
+	PNGOffset   float32 `long:"png-offset" description:"Height of the black level"`
+
+	Algorithm   string            `short:"a" long:"algo" default:"perlin" choice:"perlin" choice:"diamond-square" choice:"warped-fbm" choice:"worley" description:"Terrain generator"`
+	Config      *string           `long:"config" description:"JSON configuration of the generator, the flags below override it"`
+	Seed        *int64            `long:"seed" description:"Seed, random if unset"`
+	Scale       *float64          `long:"scale" description:"Height multiplier, the width over 50 if unset"`
+	Octaves     []internal.Octave `long:"octave" description:"Perlin octave as frequency,amplitude[,anisotropy], may be repeated"`
+	Persistence *float64          `long:"persistence" description:"Weight of every next harmonic inside an octave"`
+	Lacunarity  *float64          `long:"lacunarity" description:"Frequency multiplier of every next harmonic inside an octave"`
+	Harmonics   *int32            `long:"harmonics" description:"Number of harmonics inside an octave"`
+	Variant     *string           `long:"variant" choice:"fbm" choice:"ridged" choice:"billow" description:"Noise variant"`
+
+	ChunkX       *int     `long:"chunk-x" description:"Generate the chunk of the unbounded world instead of a map, i coordinate"`
+	ChunkY       *int     `long:"chunk-y" description:"Chunk j coordinate, 0 if unset"`
+	ChunkSize    int      `long:"chunk-size" default:"256" description:"Side of a chunk in cells"`
+	CellsPerUnit *float64 `long:"cells-per-unit" description:"World cells per unit of the noise space, 1000 if unset"`
+
+	Passes []string `short:"p" long:"pass" description:"Post-processing pass as name[:Field=value,...], e.g. hydraulic:Droplets=2,Seed=7 or thermal:Iterations=20. Applied in the given order, may be repeated"`
+
+	Texture      *string `long:"texture" description:"File path to store the texture PNG painted by the rules"`
+	TextureRules *string `long:"texture-rules" description:"JSON rules of the texture, see textures/default.json, the default ones if unset. The chunks need the rules with a HeightRange and no Water"`
+}
+
+// generator builds the configuration of the algorithm, the flags are laid over the config file
+func generator() (internal.Generator, error) {
+	var generator internal.Generator
+	if opts.Config != nil {
+		generator = internal.LoadGenerator(opts.Algorithm, *opts.Config)
+	} else {
+		var err error
+		if generator, err = internal.NewGenerator(opts.Algorithm, time.Now().UnixMilli()); err != nil {
+			return nil, err
+		}
+	}
+
+	// All the generators name the common fields the same way
+	shared := map[string]interface{}{}
+	if opts.Seed != nil {
+		shared["Seed"] = *opts.Seed
+	}
+	if opts.Scale != nil {
+		shared["Scale"] = *opts.Scale
+	}
+	if opts.CellsPerUnit != nil {
+		shared["CellsPerUnit"] = *opts.CellsPerUnit
+	}
+	data, err := json.Marshal(shared)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, generator); err != nil {
+		return nil, err
+	}
+
+	noise, ok := generator.(*internal.NoiseGenerator)
+	if !ok {
+		if len(opts.Octaves) != 0 || opts.Persistence != nil || opts.Lacunarity != nil ||
+			opts.Harmonics != nil || opts.Variant != nil {
+			return nil, fmt.Errorf("the octave flags are only for the perlin generator")
+		}
+		return generator, nil
+	}
+	if len(opts.Octaves) != 0 {
+		noise.Octaves = opts.Octaves
+	}
+	if opts.Persistence != nil {
+		noise.Persistence = *opts.Persistence
+	}
+	if opts.Lacunarity != nil {
+		noise.Lacunarity = *opts.Lacunarity
+	}
+	if opts.Harmonics != nil {
+		noise.Harmonics = *opts.Harmonics
+	}
+	if opts.Variant != nil {
+		noise.Variant = internal.NoiseVariant(*opts.Variant)
+	}
+	return noise, nil
 }
 
 func main() {
@@ -30,6 +115,79 @@ func main() {
 		writer = file
 	}
 
-	heights := internal.GenerateHeightMap(opts.Height, opts.Width)
-	heights.Flush(writer)
+	generator, err := generator()
+	if err == nil {
+		err = generator.Validate()
+	}
+	if err != nil {
+		log.WithError(err).Error("Incorrect generator configuration")
+		os.Exit(1)
+	}
+	// The configuration with the seed is the only way to get the same map again
+	config, _ := json.Marshal(generator)
+	log.WithField("config", string(config)).Info("Generating the height map")
+
+	passes := make([]internal.Pass, len(opts.Passes))
+	for idx, spec := range opts.Passes {
+		if passes[idx], err = internal.ParsePass(spec); err != nil {
+			log.WithError(err).Error("Incorrect pass")
+			os.Exit(1)
+		}
+	}
+
+	rules := texture.DefaultRules()
+	if opts.TextureRules != nil {
+		rules = texture.LoadRules(*opts.TextureRules)
+	}
+	chunk := opts.ChunkX != nil || opts.ChunkY != nil
+	if err = rules.Validate(); err == nil && chunk && opts.Texture != nil {
+		err = rules.ValidateChunks()
+	}
+	if err != nil {
+		log.WithError(err).Error("Incorrect texture rules")
+		os.Exit(1)
+	}
+
+	var heights *internal.HeightMap
+	if chunk {
+		cx, cy := 0, 0
+		if opts.ChunkX != nil {
+			cx = *opts.ChunkX
+		}
+		if opts.ChunkY != nil {
+			cy = *opts.ChunkY
+		}
+		region, ok := generator.(internal.RegionGenerator)
+		if !ok {
+			log.Errorf("The %s generator can not generate chunks", opts.Algorithm)
+			os.Exit(1)
+		}
+		heights = region.GenerateRegion(cx*opts.ChunkSize, cy*opts.ChunkSize, opts.ChunkSize, opts.ChunkSize)
+	} else {
+		heights = generator.Generate(opts.Height, opts.Width)
+	}
+	for idx, pass := range passes {
+		log.WithField("pass", opts.Passes[idx]).Info("Applying the pass")
+		pass.Apply(heights)
+	}
+	switch opts.Format {
+	case "json":
+		err = heights.Flush(writer)
+	case "binary":
+		err = heights.FlushBinary(writer, internal.BinaryOptions{})
+	case "png":
+		var used internal.PNGOptions
+		used, err = heights.FlushPNG(writer, internal.PNGOptions{Scale: opts.PNGScale, Offset: opts.PNGOffset})
+		if err == nil {
+			log.WithFields(used.Fields()).Info("Stored the PNG height map, read it back with the options")
+		}
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to write the height map")
+		os.Exit(2)
+	}
+	if opts.Texture != nil {
+		log.Info("Painting the texture")
+		common.FlushRGBA(*opts.Texture, rules.Synthesize(heights))
+	}
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"github.com/jessevdk/go-flags"
+	log "github.com/sirupsen/logrus"
+	"image"
+	"image/color"
+	"os"
+	"terrain/internal"
+	"terrain/internal/common"
+)
+
+var opts = struct {
+	Source      string   `short:"s" long:"src" required:"yes" description:"CSV with x, y and z in the first columns, x to the east and y to the north"`
+	Destination string   `short:"d" long:"dst" required:"yes" description:"File path to store the height map"`
+	Format      string   `short:"f" long:"format" default:"binary" choice:"binary" choice:"json" choice:"png" description:"Format of the result"`
+	CellSize    float64  `long:"cell-size" required:"yes" description:"Side of a cell in the units of the coordinates"`
+	Method      string   `long:"method" default:"tin" choice:"tin" choice:"idw" description:"Interpolation between the points"`
+	Power       float64  `long:"power" default:"2" description:"Power of the inverse distance weights, idw only"`
+	MaxDistance *float64 `long:"max-distance" description:"Farthest a cell may be from the closest point, 5 cells if unset. The farther cells get no data and are impassable"`
+	Texture     *string  `long:"texture" description:"File path to store a white texture with the no data cells black"`
+	PNGScale    float32  `long:"png-scale" description:"Height of a gray level of the PNG result, fitted to the range if unset"`
+	PNGOffset   float32  `long:"png-offset" description:"Height of the black level of the PNG result"`
+}{}
+
+func main() {
+	if _, err := flags.Parse(&opts); err != nil {
+		os.Exit(1)
+	}
+
+	file, err := os.Open(opts.Source)
+	if err != nil {
+		log.WithError(err).Panic("failed to open the points")
+	}
+	points, err := internal.ReadXYZ(file)
+	file.Close()
+	if err != nil {
+		log.WithError(err).Panic("failed to read the points")
+	}
+
+	options := internal.DefaultScatteredOptions(opts.CellSize)
+	options.Method = internal.InterpolationMethod(opts.Method)
+	options.Power = opts.Power
+	if opts.MaxDistance != nil {
+		options.MaxDistance = *opts.MaxDistance
+	}
+	heights, err := internal.Rasterize(points, options)
+	if err != nil {
+		log.WithError(err).Error("Failed to rasterise the points")
+		os.Exit(1)
+	}
+	iMax, jMax := heights.Bounds()
+	log.WithField("points", len(points)).Infof("Rasterised into %dx%d cells", iMax, jMax)
+
+	out, err := os.Create(opts.Destination)
+	if err != nil {
+		log.WithError(err).Error("Failed to open the destination file")
+		os.Exit(2)
+	}
+	defer out.Close()
+	switch opts.Format {
+	case "json":
+		err = heights.Flush(out)
+	case "png":
+		var used internal.PNGOptions
+		used, err = heights.FlushPNG(out, internal.PNGOptions{Scale: opts.PNGScale, Offset: opts.PNGOffset})
+		if err == nil {
+			log.WithFields(used.Fields()).Info("Stored the PNG height map, read it back with the options")
+		}
+	default:
+		err = heights.FlushBinary(out, internal.BinaryOptions{})
+	}
+	if err != nil {
+		log.WithError(err).Panic("failed to write the height map")
+	}
+
+	if opts.Texture != nil {
+		rgba := image.NewRGBA(image.Rect(0, 0, iMax, jMax))
+		for i := 0; i < iMax; i++ {
+			for j := 0; j < jMax; j++ {
+				if heights.IsNoData(i, j) {
+					rgba.SetRGBA(i, j, color.RGBA{A: 0xff})
+				} else {
+					rgba.SetRGBA(i, j, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+				}
+			}
+		}
+		common.FlushRGBA(*opts.Texture, rgba)
+	}
+}
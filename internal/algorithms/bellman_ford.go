@@ -0,0 +1,81 @@
+package algorithms
+
+import (
+	"runtime"
+	"sync/atomic"
+	"terrain/internal"
+	"terrain/internal/common"
+)
+
+// relaxFrom relabels the neighbours of the cell through it
+func relaxFrom(field *Field, dists *internal.HeightMap, i, j int, stats *Stats) {
+	dist := dists.At(i, j)
+	if dist < -0.5 {
+		return
+	}
+	for dir := 0; dir < DirectionCount; dir++ {
+		iDir, jDir := DirectionToIndexes(i, j, Direction(dir))
+		cost := field.Length(i, j, Direction(dir))
+		if cost == nil {
+			continue
+		}
+		distDir := dists.At(iDir, jDir)
+		newDist := dist + *cost
+		if distDir < -0.5 || (distDir > -0.5 && newDist < distDir) {
+			dists.SetAt(iDir, jDir, newDist)
+			stats.relaxed()
+		}
+	}
+}
+
+// BellmanFord makes NM-2 full passes over the grid relabelling the cells
+func BellmanFord(field *Field, from, to common.Position, stats *Stats) (dists *internal.HeightMap) {
+	iMax, jMax := field.Bounds()
+	dists = newDists(field, to)
+
+	stop := iMax*jMax - 2
+	for k := 0; k < stop; k++ {
+		stats.step()
+		for i := 0; i < iMax; i++ {
+			for j := 0; j < jMax; j++ {
+				relaxFrom(field, dists, i, j, stats)
+			}
+		}
+	}
+	return
+}
+
+// ParallelBellmanFord splits every pass by rows between the cores. A core
+// starts its last row only after the next core has finished its first one
+func ParallelBellmanFord(field *Field, from, to common.Position, stats *Stats) (dists *internal.HeightMap) {
+	iMax, jMax := field.Bounds()
+	dists = newDists(field, to)
+
+	numCPU := runtime.NumCPU()
+	stop := iMax*jMax - 2
+	for k := 0; k < stop; k++ {
+		stats.step()
+		batchLock := make([]int32, numCPU)
+		internal.ParallelFor(0, numCPU, 1, func(numBatch int) {
+			iFrom := iMax / numCPU * numBatch
+			iTo := iMax / numCPU * (numBatch + 1)
+			if numBatch == numCPU-1 {
+				iTo = iMax
+			}
+			for i := iFrom; i < iTo; i++ {
+				if i == iTo-1 && numBatch != numCPU-1 {
+					for atomic.LoadInt32(&batchLock[numBatch+1]) == 0 {
+						runtime.Gosched()
+					}
+				}
+				for j := 0; j < jMax; j++ {
+					relaxFrom(field, dists, i, j, stats)
+				}
+				if i == iFrom {
+					atomic.StoreInt32(&batchLock[numBatch], 1)
+				}
+			}
+		})
+	}
+	return
+}
@@ -0,0 +1,100 @@
+package algorithms
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"terrain/internal"
+	"terrain/internal/common"
+)
+
+// Catchment partitions the field by the base which reaches every cell first
+type Catchment struct {
+	Bases []common.Position
+	// Dists keeps the travel cost from the closest base, -1 if unreachable
+	Dists  *internal.HeightMap
+	owners []int
+}
+
+type CatchmentStats struct {
+	Base common.Position
+	// Area is the number of cells served by the base
+	Area     int
+	MeanCost float32
+	MaxCost  float32
+}
+
+func NewCatchment(field *Field, bases ...common.Position) (catchment *Catchment) {
+	catchment = new(Catchment)
+	catchment.Bases = bases
+	catchment.Dists, catchment.owners = costField(field, Outbound, bases)
+	return
+}
+
+// Owner returns the index of the base serving the cell, -1 if no base reaches it
+func (catchment *Catchment) Owner(i, j int) int {
+	_, jMax := catchment.Dists.Bounds()
+	return catchment.owners[i*jMax+j]
+}
+
+func (catchment *Catchment) Stats() (stats []CatchmentStats) {
+	stats = make([]CatchmentStats, len(catchment.Bases))
+	for idx, base := range catchment.Bases {
+		stats[idx].Base = base
+	}
+	iMax, jMax := catchment.Dists.Bounds()
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			owner := catchment.Owner(i, j)
+			if owner == -1 {
+				continue
+			}
+			dist := catchment.Dists.At(i, j)
+			stats[owner].Area++
+			stats[owner].MeanCost += dist
+			if dist > stats[owner].MaxCost {
+				stats[owner].MaxCost = dist
+			}
+		}
+	}
+	for idx := range stats {
+		if stats[idx].Area != 0 {
+			stats[idx].MeanCost /= float32(stats[idx].Area)
+		}
+	}
+	return
+}
+
+// LabelColor returns a colour for the label, neighbouring labels are far apart on the hue circle
+func LabelColor(label int) color.RGBA {
+	hue := math.Mod(float64(label)*0.618033988749895, 1) * 6
+	x := uint8(255 * (1 - math.Abs(math.Mod(hue, 2)-1)))
+	switch int(hue) {
+	case 0:
+		return color.RGBA{R: 0xff, G: x, A: 0xff}
+	case 1:
+		return color.RGBA{R: x, G: 0xff, A: 0xff}
+	case 2:
+		return color.RGBA{G: 0xff, B: x, A: 0xff}
+	case 3:
+		return color.RGBA{G: x, B: 0xff, A: 0xff}
+	case 4:
+		return color.RGBA{R: x, B: 0xff, A: 0xff}
+	default:
+		return color.RGBA{R: 0xff, B: x, A: 0xff}
+	}
+}
+
+// Image paints every cell with the colour of its base. Unreachable cells are transparent
+func (catchment *Catchment) Image() (rgba *image.RGBA) {
+	iMax, jMax := catchment.Dists.Bounds()
+	rgba = image.NewRGBA(image.Rect(0, 0, iMax, jMax))
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			if owner := catchment.Owner(i, j); owner != -1 {
+				rgba.SetRGBA(i, j, LabelColor(owner))
+			}
+		}
+	}
+	return
+}
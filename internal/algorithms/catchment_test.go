@@ -0,0 +1,48 @@
+package algorithms
+
+import (
+	"math"
+	"terrain/internal"
+	"terrain/internal/common"
+	"testing"
+)
+
+func TestCatchmentOwnersAreClosest(t *testing.T) {
+	field := randomField(6, 24, 20, 0.2)
+	var bases []common.Position
+	for _, base := range []common.Position{{I: 2, J: 3}, {I: 20, J: 4}, {I: 11, J: 17}, {I: 12, J: 9}} {
+		if field.IsValidIndex(base.I, base.J) {
+			bases = append(bases, base)
+		}
+	}
+	if len(bases) < 2 {
+		t.Fatal("the field has blocked the bases")
+	}
+	catchment := NewCatchment(field, bases...)
+	fields := make([]*internal.HeightMap, len(bases))
+	for idx, base := range bases {
+		fields[idx] = NewCostField(field, Outbound, base)
+	}
+	iMax, jMax := field.Bounds()
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			best := float32(-1)
+			for _, dists := range fields {
+				if dist := dists.At(i, j); dist > -0.5 && (best < -0.5 || dist < best) {
+					best = dist
+				}
+			}
+			owner := catchment.Owner(i, j)
+			if best < -0.5 {
+				if owner != -1 {
+					t.Fatalf("(%d, %d) no base reaches is owned by %d", i, j, owner)
+				}
+				continue
+			}
+			// The ties may go to either base
+			if owner == -1 || math.Abs(float64(fields[owner].At(i, j)-best)) > 1e-3 || math.Abs(float64(catchment.Dists.At(i, j)-best)) > 1e-3 {
+				t.Fatalf("(%d, %d) is owned by %d at %g, the closest base is %g away", i, j, owner, catchment.Dists.At(i, j), best)
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package algorithms
+
+import (
+	"terrain/internal"
+)
+
+// Ring is a closed polyline in cell coordinates until it is georeferenced, the
+// first point is repeated at the end
+type Ring [][2]float64
+
+// Polygon is an outer ring followed by its holes. Outer rings are counterclockwise
+type Polygon []Ring
+
+func (ring Ring) area() (area float64) {
+	for k := 0; k+1 < len(ring); k++ {
+		area += ring[k][0]*ring[k+1][1] - ring[k+1][0]*ring[k][1]
+	}
+	return area / 2
+}
+
+func (ring Ring) reverse() {
+	for a, b := 0, len(ring)-1; a < b; a, b = a+1, b-1 {
+		ring[a], ring[b] = ring[b], ring[a]
+	}
+}
+
+func (ring Ring) contains(point [2]float64) (result bool) {
+	for a, b := 0, len(ring)-1; a < len(ring); b, a = a, a+1 {
+		pa, pb := ring[a], ring[b]
+		if (pa[1] > point[1]) != (pb[1] > point[1]) &&
+			point[0] < (pb[0]-pa[0])*(point[1]-pa[1])/(pb[1]-pa[1])+pa[0] {
+			result = !result
+		}
+	}
+	return
+}
+
+// Georeference maps the rings from the cells to the coordinates of the height
+// map, see HeightMap.Coordinates. The northing falls with j, so the rings are
+// reversed to stay counterclockwise. The map without an Origin keeps the cells
+func (polygon Polygon) Georeference(hm *internal.HeightMap) (result Polygon) {
+	if hm.Origin == nil {
+		return polygon
+	}
+	result = make(Polygon, len(polygon))
+	for k, ring := range polygon {
+		result[k] = make(Ring, len(ring))
+		for idx, point := range ring {
+			x, y := hm.Coordinates(point[0], point[1])
+			result[k][idx] = [2]float64{x, y}
+		}
+		result[k].reverse()
+	}
+	return
+}
+
+// Contour traces the boundaries of the region where the cost field is at most
+// the level with the marching squares algorithm. Unreachable cells (-1) and
+// the cells outside the grid never belong to the region
+func Contour(dists *internal.HeightMap, level float32) (polygons []Polygon) {
+	polygons = make([]Polygon, 0)
+	iMax, jMax := dists.Bounds()
+	value := func(i, j int) float32 {
+		if i < 0 || j < 0 || i >= iMax || j >= jMax {
+			return -1
+		}
+		return dists.At(i, j)
+	}
+	inside := func(v float32) bool {
+		return v > -0.5 && v <= level
+	}
+	// Every grid edge is identified by its first corner and its orientation
+	edgeKey := func(i, j int, alongJ bool) int {
+		key := ((i+1)*(jMax+2) + (j + 1)) * 2
+		if alongJ {
+			key++
+		}
+		return key
+	}
+	points := map[int][2]float64{}
+	crossing := func(i1, j1, i2, j2 int) [2]float64 {
+		v1, v2 := value(i1, j1), value(i2, j2)
+		t := 0.5
+		if v1 > -0.5 && v2 > -0.5 && v1 != v2 {
+			t = float64((level - v1) / (v2 - v1))
+			if t < 0 {
+				t = 0
+			} else if t > 1 {
+				t = 1
+			}
+		}
+		return [2]float64{float64(i1) + t*float64(i2-i1), float64(j1) + t*float64(j2-j1)}
+	}
+
+	segments := make([][2]int, 0)
+	links := map[int][]int{}
+	addSegment := func(a, b int) {
+		links[a] = append(links[a], len(segments))
+		links[b] = append(links[b], len(segments))
+		segments = append(segments, [2]int{a, b})
+	}
+
+	for i := -1; i < iMax; i++ {
+		for j := -1; j < jMax; j++ {
+			corners := [4][2]int{{i, j}, {i + 1, j}, {i + 1, j + 1}, {i, j + 1}}
+			var values [4]float32
+			var in [4]bool
+			count := 0
+			for k, corner := range corners {
+				values[k] = value(corner[0], corner[1])
+				in[k] = inside(values[k])
+				if in[k] {
+					count++
+				}
+			}
+			if count == 0 || count == 4 {
+				continue
+			}
+			edges := [4]int{edgeKey(i, j, false), edgeKey(i+1, j, true), edgeKey(i, j+1, false), edgeKey(i, j, true)}
+			ends := [4][2]int{{0, 1}, {1, 2}, {3, 2}, {0, 3}}
+			for k, edge := range edges {
+				a, b := corners[ends[k][0]], corners[ends[k][1]]
+				if in[ends[k][0]] != in[ends[k][1]] {
+					if _, ok := points[edge]; !ok {
+						points[edge] = crossing(a[0], a[1], b[0], b[1])
+					}
+				}
+			}
+			// The edges touching each corner
+			touching := [4][2]int{{0, 3}, {0, 1}, {1, 2}, {2, 3}}
+			if count == 2 && in[0] == in[2] {
+				// Saddle: cut off the corners which disagree with the centre
+				centre := false
+				if values[0] > -0.5 && values[1] > -0.5 && values[2] > -0.5 && values[3] > -0.5 {
+					centre = inside((values[0] + values[1] + values[2] + values[3]) / 4)
+				}
+				for k := range corners {
+					if in[k] != centre {
+						addSegment(edges[touching[k][0]], edges[touching[k][1]])
+					}
+				}
+				continue
+			}
+			crossed := make([]int, 0, 2)
+			for k := range edges {
+				if in[ends[k][0]] != in[ends[k][1]] {
+					crossed = append(crossed, edges[k])
+				}
+			}
+			addSegment(crossed[0], crossed[1])
+		}
+	}
+
+	// Link the segments into rings
+	rings := make([]Ring, 0)
+	used := make([]bool, len(segments))
+	for first := range segments {
+		if used[first] {
+			continue
+		}
+		ring := Ring{points[segments[first][0]]}
+		current, edge := first, segments[first][1]
+		for {
+			used[current] = true
+			ring = append(ring, points[edge])
+			next := links[edge][0]
+			if next == current {
+				next = links[edge][1]
+			}
+			if used[next] {
+				break
+			}
+			current = next
+			if segments[current][0] == edge {
+				edge = segments[current][1]
+			} else {
+				edge = segments[current][0]
+			}
+		}
+		rings = append(rings, ring)
+	}
+
+	// Rings inside an even number of other rings are outer ones, the rest are holes
+	depths := make([]int, len(rings))
+	for a := range rings {
+		for b := range rings {
+			if a != b && rings[b].contains(rings[a][0]) {
+				depths[a]++
+			}
+		}
+		if isOuter := depths[a]%2 == 0; isOuter != (rings[a].area() > 0) {
+			rings[a].reverse()
+		}
+	}
+	outer := map[int]int{}
+	for a := range rings {
+		if depths[a]%2 == 0 {
+			outer[a] = len(polygons)
+			polygons = append(polygons, Polygon{rings[a]})
+		}
+	}
+	for a := range rings {
+		if depths[a]%2 == 0 {
+			continue
+		}
+		for b := range rings {
+			if depths[b] == depths[a]-1 && rings[b].contains(rings[a][0]) {
+				polygons[outer[b]] = append(polygons[outer[b]], rings[a])
+				break
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,90 @@
+package algorithms
+
+import (
+	"math"
+	"terrain/internal"
+	"testing"
+)
+
+// squareField has the cost of the king moves from the middle of the 9x9 cells
+func squareField() *internal.HeightMap {
+	dists := internal.EmptyHeightMap(9, 9)
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			dists.SetAt(i, j, float32(math.Max(math.Abs(float64(i-4)), math.Abs(float64(j-4)))))
+		}
+	}
+	return dists
+}
+
+// checkSquareRing expects a single closed counterclockwise ring at the level
+func checkSquareRing(t *testing.T, polygons []Polygon, level float32) {
+	t.Helper()
+	if len(polygons) != 1 || len(polygons[0]) != 1 {
+		t.Fatalf("traced %d polygons instead of one ring at %g", len(polygons), level)
+	}
+	ring := polygons[0][0]
+	if len(ring) < 5 || ring[0] != ring[len(ring)-1] {
+		t.Fatalf("the ring %v is not closed", ring)
+	}
+	if ring.area() <= 0 || !ring.contains([2]float64{4, 4}) {
+		t.Errorf("the ring %v is not counterclockwise around the middle", ring)
+	}
+	for _, point := range ring {
+		if distance := math.Max(math.Abs(point[0]-4), math.Abs(point[1]-4)); math.Abs(distance-float64(level)) > 1e-6 {
+			t.Errorf("the point %v is %g from the middle instead of %g", point, distance, level)
+		}
+	}
+}
+
+func TestContourSquare(t *testing.T) {
+	for _, level := range []float32{1, 1.5, 3.25} {
+		checkSquareRing(t, Contour(squareField(), level), level)
+	}
+	// Every cell is inside, the ring goes around the grid
+	if polygons := Contour(squareField(), 10); len(polygons) != 1 || polygons[0][0].area() <= 0 {
+		t.Errorf("traced %v around the grid", polygons)
+	}
+}
+
+func TestContourHole(t *testing.T) {
+	dists := squareField()
+	dists.SetAt(4, 4, -1)
+	polygons := Contour(dists, 2.5)
+	if len(polygons) != 1 || len(polygons[0]) != 2 {
+		t.Fatalf("traced %d polygons instead of one with a hole", len(polygons))
+	}
+	if polygons[0][1].area() >= 0 || !polygons[0][1].contains([2]float64{4, 4}) {
+		t.Errorf("the hole %v is not clockwise around the unreachable cell", polygons[0][1])
+	}
+}
+
+func TestNewIsochrones(t *testing.T) {
+	budgets := []float32{1.5, 2, 2.5}
+	isochrones := NewIsochrones(squareField(), budgets)
+	if len(isochrones) != len(budgets) {
+		t.Fatalf("traced %d isochrones", len(isochrones))
+	}
+	for k, isochrone := range isochrones {
+		if isochrone.Budget != budgets[k] {
+			t.Errorf("the isochrone %d has the budget %g", k, isochrone.Budget)
+		}
+		checkSquareRing(t, isochrone.Polygons, budgets[k])
+	}
+}
+
+func TestPolygonGeoreference(t *testing.T) {
+	dists := squareField()
+	dists.CellSize, dists.Origin = 10, &[2]float64{1000, 5000}
+	polygon := Contour(dists, 1.5)[0].Georeference(dists)
+	// The corner of the cell (0, 0) is the origin and the northing falls with j
+	ring := polygon[0]
+	if ring.area() <= 0 || !ring.contains([2]float64{1045, 4955}) {
+		t.Errorf("the ring %v is not counterclockwise around the middle", ring)
+	}
+	for _, point := range ring {
+		if distance := math.Max(math.Abs(point[0]-1045), math.Abs(point[1]-4955)); math.Abs(distance-15) > 1e-6 {
+			t.Errorf("the point %v is %g from the middle instead of 15", point, distance)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package algorithms
+
+import (
+	"container/heap"
+	"terrain/internal"
+	"terrain/internal/common"
+)
+
+// Flow tells whether routes of a cost field lead into or out of the sources
+type Flow int
+
+const (
+	// Inbound fields keep the cost of reaching a source, which may be
+	// impassable, see Field.Length
+	Inbound Flow = iota
+	// Outbound fields keep the cost of reaching a cell from a source
+	Outbound
+)
+
+// NewCostField computes the cost of the cheapest route between every cell and
+// the closest source with the Dijkstra algorithm. Unreachable cells are -1
+func NewCostField(field *Field, flow Flow, sources ...common.Position) (dists *internal.HeightMap) {
+	dists, _ = costField(field, flow, sources)
+	return
+}
+
+// costField also returns the index of the closest source for every cell, -1 if there is none
+func costField(field *Field, flow Flow, sources []common.Position) (dists *internal.HeightMap, owners []int) {
+	iMax, jMax := field.Bounds()
+	// Fill inf as -1
+	dists = internal.EmptyHeightMap(iMax, jMax)
+	owners = make([]int, iMax*jMax)
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			dists.SetAt(i, j, float32(-1))
+			owners[i*jMax+j] = -1
+		}
+	}
+
+	border := new(costQueue)
+	for idx, source := range sources {
+		dists.SetAt(source.I, source.J, 0)
+		owners[source.I*jMax+source.J] = idx
+		heap.Push(border, costItem{source, 0})
+	}
+	for border.Len() != 0 {
+		item := heap.Pop(border).(costItem)
+		pos := item.pos
+		if item.dist > dists.At(pos.I, pos.J) {
+			continue
+		}
+		for dir := 0; dir < DirectionCount; dir++ {
+			iDir, jDir := DirectionToIndexes(pos.I, pos.J, Direction(dir))
+			var cost *float32
+			switch flow {
+			case Inbound:
+				cost = field.Length(pos.I, pos.J, Direction(dir))
+			case Outbound:
+				if field.IsValidIndex(iDir, jDir) {
+					cost = field.Length(iDir, jDir, Direction(dir).Opposite())
+				}
+			}
+			if cost == nil {
+				continue
+			}
+			costDir, newCostDir := dists.At(iDir, jDir), item.dist+*cost
+			if costDir < -0.5 || newCostDir < costDir {
+				dists.SetAt(iDir, jDir, newCostDir)
+				owners[iDir*jMax+jDir] = owners[pos.I*jMax+pos.J]
+				heap.Push(border, costItem{common.Position{I: iDir, J: jDir}, newCostDir})
+			}
+		}
+	}
+	return
+}
+
+type costItem struct {
+	pos  common.Position
+	dist float32
+}
+
+type costQueue []costItem
+
+func (queue costQueue) Len() int { return len(queue) }
+
+func (queue costQueue) Less(a, b int) bool { return queue[a].dist < queue[b].dist }
+
+func (queue costQueue) Swap(a, b int) { queue[a], queue[b] = queue[b], queue[a] }
+
+func (queue *costQueue) Push(x interface{}) { *queue = append(*queue, x.(costItem)) }
+
+func (queue *costQueue) Pop() interface{} {
+	old := *queue
+	item := old[len(old)-1]
+	*queue = old[:len(old)-1]
+	return item
+}
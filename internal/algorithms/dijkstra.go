@@ -0,0 +1,126 @@
+package algorithms
+
+import (
+	"runtime"
+	"terrain/internal"
+	"terrain/internal/common"
+)
+
+// relaxNeighbours relabels the neighbours of the settled cell and calls add
+// for every one of them which has not been settled yet
+func relaxNeighbours(field *Field, dists *internal.HeightMap, usedNodes map[common.Position]struct{},
+	pos common.Position, add func(common.Position), stats *Stats) {
+	for i := 0; i < DirectionCount; i++ {
+		cost := field.Length(pos.I, pos.J, Direction(i))
+		if cost == nil {
+			continue
+		}
+		iDir, jDir := DirectionToIndexes(pos.I, pos.J, Direction(i))
+		if _, ok := usedNodes[common.Position{I: iDir, J: jDir}]; ok {
+			continue
+		}
+		costDir, newCostDir := dists.At(iDir, jDir), dists.At(pos.I, pos.J)+*cost
+		if costDir < -0.5 || (costDir > -0.5 && newCostDir < costDir) {
+			dists.SetAt(iDir, jDir, newCostDir)
+			stats.relaxed()
+		}
+		add(common.Position{I: iDir, J: jDir})
+	}
+}
+
+// Dijkstra keeps the border in a hash table and scans it for the minimum
+func Dijkstra(field *Field, from, to common.Position, stats *Stats) (dists *internal.HeightMap) {
+	usedNodes := map[common.Position]struct{}{}
+	borderNodes := map[common.Position]struct{}{to: {}}
+	dists = newDists(field, to)
+
+	for len(borderNodes) != 0 {
+		stats.step()
+
+		minDist, minPosition := float32(-1), common.Position{I: 0, J: 0}
+		for borderNode := range borderNodes {
+			dist := dists.At(borderNode.I, borderNode.J)
+			if minDist < -0.5 || (minDist > -0.5 && dist < minDist) {
+				minDist, minPosition = dist, borderNode
+			}
+		}
+		usedNodes[minPosition] = struct{}{}
+		if minPosition == from {
+			break
+		}
+		delete(borderNodes, minPosition)
+		relaxNeighbours(field, dists, usedNodes, minPosition, func(pos common.Position) {
+			borderNodes[pos] = struct{}{}
+		}, stats)
+	}
+	return
+}
+
+// ParallelDijkstra keeps the border in a hash table per core, every core
+// looks for the minimum of its own table
+func ParallelDijkstra(field *Field, from, to common.Position, stats *Stats) (dists *internal.HeightMap) {
+	usedNodes := map[common.Position]struct{}{}
+
+	numCPU := runtime.NumCPU()
+	borderNodes := make([]map[common.Position]struct{}, numCPU)
+	for i := range borderNodes {
+		borderNodes[i] = make(map[common.Position]struct{})
+	}
+	borderNodes[0][to] = struct{}{}
+	borderLen := func() (count int) {
+		for _, batch := range borderNodes {
+			count += len(batch)
+		}
+		return
+	}
+	borderAdd := func(pos common.Position) {
+		count, idx := len(borderNodes[0]), 0
+		for i, batch := range borderNodes {
+			if _, ok := batch[pos]; ok {
+				return
+			}
+			if len(batch) < count {
+				count, idx = len(batch), i
+			}
+		}
+		borderNodes[idx][pos] = struct{}{}
+	}
+	borderRemove := func(pos common.Position, batchIdx int) {
+		delete(borderNodes[batchIdx], pos)
+	}
+
+	dists = newDists(field, to)
+	for borderLen() != 0 {
+		stats.step()
+
+		batchResult := make([]common.Position, numCPU)
+		internal.ParallelFor(0, numCPU, 1, func(batchNum int) {
+			minDist, minPosition := float32(-1), common.Position{I: -1, J: -1}
+			for borderNode := range borderNodes[batchNum] {
+				dist := dists.At(borderNode.I, borderNode.J)
+				if minDist < -0.5 || (minDist > -0.5 && dist < minDist) {
+					minDist, minPosition = dist, borderNode
+				}
+			}
+			batchResult[batchNum] = minPosition
+		})
+
+		minDist, minPosition, minBatch := float32(-1), common.Position{I: 0, J: 0}, 0
+		for idx, pos := range batchResult {
+			if pos.I == -1 {
+				continue
+			}
+			dist := dists.At(pos.I, pos.J)
+			if minDist < -0.5 || (minDist > -0.5 && dist < minDist) {
+				minDist, minPosition, minBatch = dist, pos, idx
+			}
+		}
+		usedNodes[minPosition] = struct{}{}
+		if minPosition == from {
+			break
+		}
+		borderRemove(minPosition, minBatch)
+		relaxNeighbours(field, dists, usedNodes, minPosition, borderAdd, stats)
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package algorithms
+
+import (
+	"math"
+	"runtime"
+	"terrain/internal"
+)
+
+// edtInf stands for the infinite distance, it has to stay finite for the arithmetic below
+const edtInf = 1e20
+
+// edt1D computes the squared distance transform of the sampled function
+// as the lower envelope of parabolas (Felzenszwalb & Huttenlocher)
+func edt1D(f, d []float64, v []int, z []float64) {
+	n := len(f)
+	k := 0
+	v[0], z[0], z[1] = 0, math.Inf(-1), math.Inf(1)
+	for q := 1; q < n; q++ {
+		s := ((f[q] + float64(q*q)) - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+		for s <= z[k] {
+			k--
+			s = ((f[q] + float64(q*q)) - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+		}
+		k++
+		v[k], z[k], z[k+1] = q, s, math.Inf(1)
+	}
+	k = 0
+	for q := 0; q < n; q++ {
+		for z[k+1] < float64(q) {
+			k++
+		}
+		d[q] = float64((q-v[k])*(q-v[k])) + f[v[k]]
+	}
+}
+
+// DistanceTransform returns the exact Euclidean distance in cells from every
+// cell to the closest obstacle. It is +Inf if there are no obstacles at all
+func DistanceTransform(iMax, jMax int, obstacle func(i, j int) bool) (dists []float32) {
+	squared := make([]float64, iMax*jMax)
+	numCPU := runtime.NumCPU()
+
+	// Rows first
+	internal.ParallelFor(0, numCPU, 1, func(batchNum int) {
+		f, d := make([]float64, jMax), make([]float64, jMax)
+		v, z := make([]int, jMax), make([]float64, jMax+1)
+		for i := batchNum; i < iMax; i += numCPU {
+			for j := 0; j < jMax; j++ {
+				f[j] = edtInf
+				if obstacle(i, j) {
+					f[j] = 0
+				}
+			}
+			edt1D(f, d, v, z)
+			copy(squared[i*jMax:(i+1)*jMax], d)
+		}
+	})
+	// Then columns
+	internal.ParallelFor(0, numCPU, 1, func(batchNum int) {
+		f, d := make([]float64, iMax), make([]float64, iMax)
+		v, z := make([]int, iMax), make([]float64, iMax+1)
+		for j := batchNum; j < jMax; j += numCPU {
+			for i := 0; i < iMax; i++ {
+				f[i] = squared[i*jMax+j]
+			}
+			edt1D(f, d, v, z)
+			for i := 0; i < iMax; i++ {
+				squared[i*jMax+j] = d[i]
+			}
+		}
+	})
+
+	dists = make([]float32, iMax*jMax)
+	for idx, value := range squared {
+		if value >= edtInf {
+			dists[idx] = inf
+		} else {
+			dists[idx] = float32(math.Sqrt(value))
+		}
+	}
+	return
+}
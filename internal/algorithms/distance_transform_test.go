@@ -0,0 +1,32 @@
+package algorithms
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDistanceTransformBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for _, density := range []float64{0, 0.002, 0.05, 0.3} {
+		iMax, jMax := 23, 31
+		mask := make([]bool, iMax*jMax)
+		for idx := range mask {
+			mask[idx] = r.Float64() < density
+		}
+		dists := DistanceTransform(iMax, jMax, func(i, j int) bool { return mask[i*jMax+j] })
+		for i := 0; i < iMax; i++ {
+			for j := 0; j < jMax; j++ {
+				want := math.Inf(1)
+				for idx, obstacle := range mask {
+					if obstacle {
+						want = math.Min(want, math.Hypot(float64(i-idx/jMax), float64(j-idx%jMax)))
+					}
+				}
+				if got := float64(dists[i*jMax+j]); got != want && math.Abs(got-want) > 1e-5 {
+					t.Fatalf("density %g: (%d, %d) is %g instead of %g", density, i, j, got, want)
+				}
+			}
+		}
+	}
+}
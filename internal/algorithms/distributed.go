@@ -0,0 +1,187 @@
+package algorithms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"terrain/internal"
+	"terrain/internal/common"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type BorderNode struct {
+	Position common.Position
+	Dist     float32
+}
+
+// FollowerRequest is the incremental change of the border part kept by a follower
+type FollowerRequest struct {
+	// Reset drops the state left from the previous search
+	Reset bool
+	// Use is the node the master has settled, if the follower keeps it
+	Use *common.Position
+	// Add are the new nodes of the follower and the nodes with improved labels
+	Add []BorderNode
+}
+
+type FollowerResponse struct {
+	// Empty tells that the follower has no border nodes left
+	Empty       bool
+	MinPosition common.Position
+}
+
+// Follower keeps a part of the border for the distributed Dijkstra algorithm
+// and looks for its minimum on the request of the master
+type Follower struct {
+	mu     sync.Mutex
+	border map[common.Position]float32
+}
+
+func NewFollower() (follower *Follower) {
+	follower = new(Follower)
+	follower.border = make(map[common.Position]float32)
+	return
+}
+
+// Apply updates the border and returns its minimum
+func (follower *Follower) Apply(request *FollowerRequest) (response FollowerResponse) {
+	follower.mu.Lock()
+	defer follower.mu.Unlock()
+
+	if request.Reset {
+		follower.border = make(map[common.Position]float32)
+	}
+	if request.Use != nil {
+		delete(follower.border, *request.Use)
+	}
+	for _, node := range request.Add {
+		follower.border[node.Position] = node.Dist
+	}
+
+	minDist := float32(-1)
+	response.Empty = true
+	for pos, dist := range follower.border {
+		if minDist < -0.5 || dist < minDist {
+			minDist, response.MinPosition, response.Empty = dist, pos, false
+		}
+	}
+	return
+}
+
+func (follower *Follower) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request := new(FollowerRequest)
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	response := follower.Apply(request)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func askFollower(client *http.Client, url string, request *FollowerRequest) (response *FollowerResponse, err error) {
+	msgData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewBuffer(msgData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("follower %s answered %s: %s", url, resp.Status, respData)
+	}
+	response = new(FollowerResponse)
+	if err = json.Unmarshal(respData, response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return
+}
+
+// NewDistributedDijkstra returns the Dijkstra solver which keeps the border
+// on the followers. The master sends every follower the incremental change of
+// its part after each iteration and picks the minimum of their answers
+func NewDistributedDijkstra(followers []string, client *http.Client) Solver {
+	return func(field *Field, from, to common.Position, stats *Stats) (dists *internal.HeightMap) {
+		usedNodes := map[common.Position]struct{}{}
+		owners := map[common.Position]int{to: 0}
+		sizes := make([]int, len(followers))
+		requests := make([]*FollowerRequest, len(followers))
+		for idx := range requests {
+			requests[idx] = &FollowerRequest{Reset: true}
+		}
+		requests[0].Add, sizes[0] = []BorderNode{{to, 0}}, 1
+
+		dists = newDists(field, to)
+		for {
+			stats.step()
+
+			started := time.Now()
+			batchResult := make([]*FollowerResponse, len(followers))
+			internal.ParallelFor(0, len(followers), 1, func(batchNum int) {
+				response, err := askFollower(client, followers[batchNum], requests[batchNum])
+				if err != nil {
+					log.WithError(err).Panic("failed to sync the follower")
+				}
+				batchResult[batchNum] = response
+			})
+			if stats != nil {
+				stats.Network += time.Since(started)
+			}
+
+			minDist, minPosition, found := float32(-1), common.Position{}, false
+			for _, response := range batchResult {
+				if response.Empty {
+					continue
+				}
+				dist := dists.At(response.MinPosition.I, response.MinPosition.J)
+				if minDist < -0.5 || dist < minDist {
+					minDist, minPosition, found = dist, response.MinPosition, true
+				}
+			}
+			if !found {
+				break
+			}
+			usedNodes[minPosition] = struct{}{}
+			if minPosition == from {
+				break
+			}
+
+			owner := owners[minPosition]
+			delete(owners, minPosition)
+			sizes[owner]--
+			for idx := range requests {
+				requests[idx] = &FollowerRequest{}
+			}
+			requests[owner].Use = &minPosition
+			relaxNeighbours(field, dists, usedNodes, minPosition, func(pos common.Position) {
+				idx, ok := owners[pos]
+				if !ok {
+					// The least loaded follower gets the new node
+					for k := range sizes {
+						if sizes[k] < sizes[idx] {
+							idx = k
+						}
+					}
+					owners[pos] = idx
+					sizes[idx]++
+				}
+				requests[idx].Add = append(requests[idx].Add, BorderNode{pos, dists.At(pos.I, pos.J)})
+			}, stats)
+		}
+		return
+	}
+}
@@ -0,0 +1,300 @@
+package algorithms
+
+import (
+	"container/heap"
+	"math"
+	"terrain/internal"
+	"terrain/internal/common"
+)
+
+var inf = float32(math.Inf(1))
+
+// DStarLite is an incremental planner: once the field changes it repairs
+// the previous search instead of restarting it from scratch.
+//
+// The search runs from the goal, so g keeps the cost of reaching the goal
+// from every explored cell, the same way the other solvers fill dists.
+type DStarLite struct {
+	field       *Field
+	start, last common.Position
+	goal        common.Position
+	km          float32
+	g, rhs      []float32
+	queue       dstarQueue
+	stats       *Stats
+}
+
+func NewDStarLite(field *Field, from, to common.Position) (planner *DStarLite) {
+	iMax, jMax := field.Bounds()
+	planner = new(DStarLite)
+	planner.field = field
+	planner.start, planner.last, planner.goal = from, from, to
+	planner.g = make([]float32, iMax*jMax)
+	planner.rhs = make([]float32, iMax*jMax)
+	for idx := range planner.g {
+		planner.g[idx], planner.rhs[idx] = inf, inf
+	}
+	planner.queue.index = make([]int, iMax*jMax)
+	for idx := range planner.queue.index {
+		planner.queue.index[idx] = -1
+	}
+	planner.rhs[planner.offset(to)] = 0
+	planner.queue.push(planner.offset(to), planner.key(to))
+	return
+}
+
+func (planner *DStarLite) offset(pos common.Position) int {
+	_, jMax := planner.field.Bounds()
+	return pos.I*jMax + pos.J
+}
+
+func (planner *DStarLite) position(offset int) common.Position {
+	_, jMax := planner.field.Bounds()
+	return common.Position{I: offset / jMax, J: offset % jMax}
+}
+
+// heuristic never overestimates the cost since no step is cheaper than the cheapest class allows
+func (planner *DStarLite) heuristic(from, to common.Position) float32 {
+	di, dj := from.I-to.I, from.J-to.J
+	if di < 0 {
+		di = -di
+	}
+	if dj < 0 {
+		dj = -dj
+	}
+	if di < dj {
+		di = dj
+	}
+	return float32(di) * planner.field.Profile.minStepCost()
+}
+
+func (planner *DStarLite) key(pos common.Position) dstarKey {
+	offset := planner.offset(pos)
+	m := planner.g[offset]
+	if planner.rhs[offset] < m {
+		m = planner.rhs[offset]
+	}
+	return dstarKey{m + planner.heuristic(planner.start, pos) + planner.km, m}
+}
+
+// step returns the neighbour in the direction and the cost of moving there
+func (planner *DStarLite) step(from common.Position, dir Direction) (to common.Position, cost float32) {
+	i, j := DirectionToIndexes(from.I, from.J, dir)
+	to = common.Position{I: i, J: j}
+	iMax, jMax := planner.field.Bounds()
+	if i < 0 || j < 0 || i >= iMax || j >= jMax {
+		return to, inf
+	}
+	// The goal may be impassable, see Field.Length
+	if to != planner.goal && !planner.field.IsValidIndex(i, j) {
+		return to, inf
+	}
+	length := planner.field.Length(i, j, dir.Opposite())
+	if length == nil {
+		return to, inf
+	}
+	return to, *length
+}
+
+func (planner *DStarLite) updateVertex(pos common.Position) {
+	if iMax, jMax := planner.field.Bounds(); pos.I < 0 || pos.J < 0 || pos.I >= iMax || pos.J >= jMax {
+		return
+	}
+	offset := planner.offset(pos)
+	if pos != planner.goal {
+		rhs := inf
+		for dir := 0; dir < DirectionCount; dir++ {
+			to, cost := planner.step(pos, Direction(dir))
+			if cost == inf {
+				continue
+			}
+			if value := cost + planner.g[planner.offset(to)]; value < rhs {
+				rhs = value
+			}
+		}
+		planner.rhs[offset] = rhs
+	}
+	planner.queue.remove(offset)
+	if planner.g[offset] != planner.rhs[offset] {
+		planner.queue.push(offset, planner.key(pos))
+	}
+}
+
+func (planner *DStarLite) updateNeighbours(pos common.Position) {
+	for dir := 0; dir < DirectionCount; dir++ {
+		i, j := DirectionToIndexes(pos.I, pos.J, Direction(dir))
+		planner.updateVertex(common.Position{I: i, J: j})
+	}
+}
+
+// Replan brings the search up to date with all the reported changes
+func (planner *DStarLite) Replan() {
+	startOffset := planner.offset(planner.start)
+	for planner.queue.Len() != 0 {
+		top := planner.queue.top()
+		if !top.key.less(planner.key(planner.start)) &&
+			planner.rhs[startOffset] == planner.g[startOffset] {
+			break
+		}
+		planner.stats.step()
+		offset := planner.queue.pop().offset
+		pos := planner.position(offset)
+		if newKey := planner.key(pos); top.key.less(newKey) {
+			planner.queue.push(offset, newKey)
+		} else if planner.g[offset] > planner.rhs[offset] {
+			planner.g[offset] = planner.rhs[offset]
+			planner.stats.relaxed()
+			planner.updateNeighbours(pos)
+		} else {
+			planner.g[offset] = inf
+			planner.updateVertex(pos)
+			planner.updateNeighbours(pos)
+		}
+	}
+}
+
+// UpdateCells must be called after the colour or the height of the cells has
+// been changed in the field
+func (planner *DStarLite) UpdateCells(cells ...common.Position) {
+	planner.km += planner.heuristic(planner.last, planner.start)
+	planner.last = planner.start
+	// With a footprint an obstacle changes the costs all around it
+	spread := planner.field.ClearanceRange()
+	if spread != 0 {
+		planner.field.UpdateClearance()
+	}
+	affected := map[common.Position]struct{}{}
+	for _, cell := range cells {
+		for i := cell.I - spread; i <= cell.I+spread; i++ {
+			for j := cell.J - spread; j <= cell.J+spread; j++ {
+				affected[common.Position{I: i, J: j}] = struct{}{}
+			}
+		}
+	}
+	for cell := range affected {
+		planner.updateVertex(cell)
+		planner.updateNeighbours(cell)
+	}
+}
+
+// MoveTo changes the start position, e.g. when the robot has driven along
+// the path. The goal stays the same
+func (planner *DStarLite) MoveTo(pos common.Position) {
+	planner.start = pos
+}
+
+// Cost returns the cost of the current path or -1 if the goal is unreachable
+func (planner *DStarLite) Cost() float32 {
+	cost := planner.g[planner.offset(planner.start)]
+	if cost == inf {
+		return -1
+	}
+	return cost
+}
+
+// Dists returns the labels in the form the other solvers fill them
+func (planner *DStarLite) Dists() (dists *internal.HeightMap) {
+	iMax, jMax := planner.field.Bounds()
+	dists = internal.EmptyHeightMap(iMax, jMax)
+	for idx, g := range planner.g {
+		if g == inf {
+			g = -1
+		}
+		dists.Heights[idx] = g
+	}
+	return
+}
+
+// SolveDStarLite runs a single search of the planner as a Solver
+func SolveDStarLite(field *Field, from, to common.Position, stats *Stats) (dists *internal.HeightMap) {
+	planner := NewDStarLite(field, from, to)
+	planner.stats = stats
+	planner.Replan()
+	return planner.Dists()
+}
+
+// Path returns the current path from the start to the goal, nil if the goal is unreachable
+func (planner *DStarLite) Path() (result []common.Position) {
+	if planner.Cost() < -0.5 {
+		return nil
+	}
+	result = make([]common.Position, 0)
+	for pos := planner.start; pos != planner.goal; {
+		result = append(result, pos)
+		minDist, minPosition := inf, pos
+		for dir := 0; dir < DirectionCount; dir++ {
+			to, cost := planner.step(pos, Direction(dir))
+			if cost == inf {
+				continue
+			}
+			if dist := cost + planner.g[planner.offset(to)]; dist < minDist {
+				minDist, minPosition = dist, to
+			}
+		}
+		if minDist == inf {
+			return nil
+		}
+		pos = minPosition
+	}
+	return append(result, planner.goal)
+}
+
+type dstarKey struct {
+	k1, k2 float32
+}
+
+func (key dstarKey) less(other dstarKey) bool {
+	return key.k1 < other.k1 || (key.k1 == other.k1 && key.k2 < other.k2)
+}
+
+type dstarItem struct {
+	offset int
+	key    dstarKey
+}
+
+// dstarQueue is a binary heap which remembers where every cell is stored
+type dstarQueue struct {
+	items []dstarItem
+	index []int
+}
+
+func (queue *dstarQueue) Len() int { return len(queue.items) }
+
+func (queue *dstarQueue) Less(a, b int) bool { return queue.items[a].key.less(queue.items[b].key) }
+
+func (queue *dstarQueue) Swap(a, b int) {
+	queue.items[a], queue.items[b] = queue.items[b], queue.items[a]
+	queue.index[queue.items[a].offset] = a
+	queue.index[queue.items[b].offset] = b
+}
+
+func (queue *dstarQueue) Push(x interface{}) {
+	item := x.(dstarItem)
+	queue.index[item.offset] = len(queue.items)
+	queue.items = append(queue.items, item)
+}
+
+func (queue *dstarQueue) Pop() interface{} {
+	item := queue.items[len(queue.items)-1]
+	queue.items = queue.items[:len(queue.items)-1]
+	queue.index[item.offset] = -1
+	return item
+}
+
+func (queue *dstarQueue) top() dstarItem {
+	return queue.items[0]
+}
+
+func (queue *dstarQueue) push(offset int, key dstarKey) {
+	heap.Push(queue, dstarItem{offset, key})
+}
+
+func (queue *dstarQueue) pop() dstarItem {
+	return heap.Pop(queue).(dstarItem)
+}
+
+func (queue *dstarQueue) remove(offset int) {
+	if idx := queue.index[offset]; idx != -1 {
+		heap.Remove(queue, idx)
+	}
+}
@@ -2,17 +2,21 @@ package algorithms
 
 import (
 	"image"
+	"math"
 	"terrain/internal"
 
 	log "github.com/sirupsen/logrus"
 )
 
 type Field struct {
-	HeightMap *internal.HeightMap
+	HeightMap internal.Grid
 	RGBA      *image.RGBA
+	Profile   *Profile
+	// clearance keeps the distance in cells to the closest obstacle, nil if the profile does not need it
+	clearance []float32
 }
 
-func NewField(heights *internal.HeightMap, rgba *image.RGBA) (field *Field) {
+func NewField(heights internal.Grid, rgba *image.RGBA) (field *Field) {
 	iMax, jMax := heights.Bounds()
 	if rgba.Rect.Size().X < iMax || rgba.Rect.Size().Y < jMax {
 		log.Panic("Incorrect sizes")
@@ -20,9 +24,45 @@ func NewField(heights *internal.HeightMap, rgba *image.RGBA) (field *Field) {
 	field = new(Field)
 	field.HeightMap = heights
 	field.RGBA = rgba
+	field.Profile = DefaultProfile()
 	return
 }
 
+// SetProfile changes the vehicle the costs are computed for
+func (field *Field) SetProfile(profile *Profile) {
+	field.Profile = profile
+	field.UpdateClearance()
+}
+
+// UpdateClearance recomputes the distances to the obstacles. It must be
+// called after the texture has been changed if the vehicle has a footprint
+func (field *Field) UpdateClearance() {
+	if field.Profile.FootprintRadius <= 0 && field.Profile.ProximityDistance <= 0 {
+		field.clearance = nil
+		return
+	}
+	iMax, jMax := field.Bounds()
+	field.clearance = DistanceTransform(iMax, jMax, field.isObstacle)
+}
+
+// ClearanceRange returns how far in cells an obstacle affects the costs
+func (field *Field) ClearanceRange() int {
+	if field.clearance == nil {
+		return 0
+	}
+	radius := field.Profile.FootprintRadius
+	if field.Profile.ProximityDistance > radius {
+		radius = field.Profile.ProximityDistance
+	}
+	return int(math.Ceil(float64(radius/field.HeightMap.Spacing()))) + 1
+}
+
+// distance returns the distance to the closest obstacle in height map units
+func (field *Field) distance(i, j int) float32 {
+	_, jMax := field.Bounds()
+	return field.clearance[i*jMax+j] * field.HeightMap.Spacing()
+}
+
 func (field *Field) Bounds() (iMax, jMax int) {
 	return field.HeightMap.Bounds()
 }
@@ -41,6 +81,11 @@ const (
 	NorthWest
 )
 
+// Opposite returns the direction pointing back
+func (dir Direction) Opposite() Direction {
+	return (dir + Direction(DirectionCount/2)) % Direction(DirectionCount)
+}
+
 type Color int
 
 const (
@@ -81,6 +126,12 @@ func DirectionToIndexes(i, j int, dir Direction) (int, int) {
 	}
 }
 
+// isObstacle tells whether the cell is impassable by its colour or has no height measured
+func (field *Field) isObstacle(i, j int) bool {
+	return field.color(i, j) == BlackColor || field.Profile.speed(field.RGBA.RGBAAt(i, j)) <= 0 ||
+		field.HeightMap.IsNoData(i, j)
+}
+
 func (field *Field) IsValidIndex(i, j int) bool {
 	if i < 0 || j < 0 {
 		return false
@@ -89,7 +140,11 @@ func (field *Field) IsValidIndex(i, j int) bool {
 	if i >= iMax || j >= jMax {
 		return false
 	}
-	if field.color(i, j) == BlackColor {
+	if field.isObstacle(i, j) {
+		return false
+	}
+	// The vehicle does not fit between the obstacles
+	if field.clearance != nil && field.distance(i, j) < field.Profile.FootprintRadius {
 		return false
 	}
 	return true
@@ -97,17 +152,38 @@ func (field *Field) IsValidIndex(i, j int) bool {
 
 const requireCost float32 = 10
 
+// Length returns the cost of moving into the cell (i, j) from its neighbour
+// in the direction, nil if the move is illegal. Only the cell moved from has
+// to be passable, so a goal painted black or too close to an obstacle is still
+// reached: the solvers and the path checks treat the goal this way
 func (field *Field) Length(i, j int, dir Direction) *float32 {
 	iFrom, jFrom := DirectionToIndexes(i, j, dir)
 	if !field.IsValidIndex(iFrom, jFrom) {
 		return nil
 	}
+	profile := field.Profile
+	stepCost := profile.StepCost
+	if speed := profile.speed(field.RGBA.RGBAAt(i, j)); speed > 0 {
+		stepCost /= speed
+	}
+	if field.clearance != nil && profile.ProximityDistance > 0 {
+		if distance := field.distance(i, j); distance < profile.ProximityDistance {
+			stepCost += profile.ProximityCost * (1 - distance/profile.ProximityDistance)
+		}
+	}
 	heightFrom, heightTo := field.HeightMap.At(iFrom, jFrom), field.HeightMap.At(i, j)
 	if heightTo > heightFrom {
-		result := (heightTo-heightFrom)*100 + requireCost
+		distance := field.HeightMap.Spacing()
+		if iFrom != i && jFrom != j {
+			distance *= math.Sqrt2
+		}
+		if heightTo-heightFrom > profile.maxRise(distance) {
+			return nil
+		}
+		result := (heightTo-heightFrom)*profile.UphillCost + stepCost
 		return &result
 	} else {
-		result := requireCost
+		result := stepCost
 		return &result
 	}
 }
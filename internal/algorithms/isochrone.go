@@ -0,0 +1,63 @@
+package algorithms
+
+import (
+	"image"
+	"image/color"
+	"sort"
+	"terrain/internal"
+)
+
+// Isochrone is the region reachable within the budget
+type Isochrone struct {
+	Budget   float32
+	Polygons []Polygon
+}
+
+// NewIsochrones traces the region of the cost field for every budget
+func NewIsochrones(dists *internal.HeightMap, budgets []float32) (isochrones []Isochrone) {
+	isochrones = make([]Isochrone, len(budgets))
+	internal.ParallelFor(0, len(budgets), 1, func(k int) {
+		isochrones[k] = Isochrone{Budget: budgets[k], Polygons: Contour(dists, budgets[k])}
+	})
+	return
+}
+
+// BandColor returns the colour of the k-th of n budget bands, from green to red
+func BandColor(k, n int) color.RGBA {
+	t := float32(0)
+	if n > 1 {
+		t = float32(k) / float32(n-1)
+	}
+	return color.RGBA{R: uint8(255 * t), G: uint8(255 * (1 - t)), B: 0, A: 0xff}
+}
+
+// IsochroneOverlay paints the cells of the texture by the smallest budget
+// they are reachable within
+func IsochroneOverlay(rgba *image.RGBA, dists *internal.HeightMap, budgets []float32) (overlay *image.RGBA) {
+	sorted := append([]float32{}, budgets...)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+
+	overlay = image.NewRGBA(rgba.Rect)
+	copy(overlay.Pix, rgba.Pix)
+	iMax, jMax := dists.Bounds()
+	internal.ParallelFor(0, iMax, 1, func(i int) {
+		for j := 0; j < jMax; j++ {
+			dist := dists.At(i, j)
+			if dist < -0.5 {
+				continue
+			}
+			band := sort.Search(len(sorted), func(k int) bool { return dist <= sorted[k] })
+			if band == len(sorted) {
+				continue
+			}
+			base, paint := rgba.RGBAAt(i, j), BandColor(band, len(sorted))
+			overlay.SetRGBA(i, j, color.RGBA{
+				R: uint8((uint16(base.R) + uint16(paint.R)) / 2),
+				G: uint8((uint16(base.G) + uint16(paint.G)) / 2),
+				B: uint8((uint16(base.B) + uint16(paint.B)) / 2),
+				A: 0xff,
+			})
+		}
+	})
+	return
+}
@@ -0,0 +1,159 @@
+package algorithms
+
+import (
+	"container/heap"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"terrain/internal"
+	"terrain/internal/common"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// OutOfCoreOptions tells where and how the labels of OutOfCoreDijkstra are stored
+type OutOfCoreOptions struct {
+	// Dir keeps the dists and the visited flags, a temporary directory if empty
+	Dir string
+	// TileSize is the side of a tile in cells
+	TileSize int
+	// CacheSize is the number of tiles of every grid kept in memory
+	CacheSize int
+}
+
+type bucketEntry struct {
+	pos  common.Position
+	dist float32
+}
+
+// bucketQueue groups the border by the cost. A bucket is as wide as the
+// cheapest step, so no cell of the bucket can improve another one of the
+// same bucket and the whole bucket can be settled in any order. The cells
+// are settled tile by tile to keep the swaps of the tiles low
+type bucketQueue struct {
+	width   float32
+	buckets map[int64][]bucketEntry
+	order   bucketOrder
+}
+
+func (queue *bucketQueue) push(pos common.Position, dist float32) {
+	idx := int64(dist / queue.width)
+	if _, ok := queue.buckets[idx]; !ok {
+		heap.Push(&queue.order, idx)
+	}
+	queue.buckets[idx] = append(queue.buckets[idx], bucketEntry{pos, dist})
+}
+
+// pop takes the cheapest bucket out of the queue sorted by the tiles
+func (queue *bucketQueue) pop(tileSize int) (entries []bucketEntry) {
+	idx := heap.Pop(&queue.order).(int64)
+	entries = queue.buckets[idx]
+	delete(queue.buckets, idx)
+	sort.Slice(entries, func(a, b int) bool {
+		pa, pb := entries[a].pos, entries[b].pos
+		ta, tb := [2]int{pa.I / tileSize, pa.J / tileSize}, [2]int{pb.I / tileSize, pb.J / tileSize}
+		if ta != tb {
+			return ta[0] < tb[0] || (ta[0] == tb[0] && ta[1] < tb[1])
+		}
+		return pa.I < pb.I || (pa.I == pb.I && pa.J < pb.J)
+	})
+	return
+}
+
+type bucketOrder []int64
+
+func (order bucketOrder) Len() int { return len(order) }
+
+func (order bucketOrder) Less(a, b int) bool { return order[a] < order[b] }
+
+func (order bucketOrder) Swap(a, b int) { order[a], order[b] = order[b], order[a] }
+
+func (order *bucketOrder) Push(x interface{}) { *order = append(*order, x.(int64)) }
+
+func (order *bucketOrder) Pop() interface{} {
+	old := *order
+	item := old[len(old)-1]
+	*order = old[:len(old)-1]
+	return item
+}
+
+// OutOfCoreDijkstra searches the same way as Dijkstra, but keeps the dists and
+// the visited flags in tiled grids on the disk, so that only the border and
+// the cached tiles stay in memory. The step cost of the profile must be positive
+func OutOfCoreDijkstra(field *Field, from, to common.Position, options OutOfCoreOptions, stats *Stats) (dists *internal.TiledHeightMap, err error) {
+	width := field.Profile.minStepCost()
+	if width <= 0 || math.IsInf(float64(width), 0) {
+		log.Panic("the out-of-core solver needs a positive step cost")
+	}
+
+	iMax, jMax := field.Bounds()
+	if dists, err = internal.CreateTiledHeightMap(filepath.Join(options.Dir, "dists"),
+		iMax, jMax, options.TileSize, field.HeightMap.Spacing(), options.CacheSize); err != nil {
+		return
+	}
+	// Fill inf as -1
+	if err = dists.SetFill(-1); err != nil {
+		return
+	}
+	visited, err := internal.CreateTiledHeightMap(filepath.Join(options.Dir, "visited"),
+		iMax, jMax, options.TileSize, 0, options.CacheSize)
+	if err != nil {
+		return
+	}
+
+	queue := &bucketQueue{width: width, buckets: map[int64][]bucketEntry{}}
+	dists.SetAt(to.I, to.J, 0)
+	queue.push(to, 0)
+	for queue.order.Len() != 0 {
+		for _, entry := range queue.pop(options.TileSize) {
+			pos := entry.pos
+			if visited.At(pos.I, pos.J) != 0 || entry.dist > dists.At(pos.I, pos.J) {
+				continue
+			}
+			stats.step()
+			visited.SetAt(pos.I, pos.J, 1)
+			if pos == from {
+				return dists, dists.Flush()
+			}
+			for dir := 0; dir < DirectionCount; dir++ {
+				cost := field.Length(pos.I, pos.J, Direction(dir))
+				if cost == nil {
+					continue
+				}
+				iDir, jDir := DirectionToIndexes(pos.I, pos.J, Direction(dir))
+				if visited.At(iDir, jDir) != 0 {
+					continue
+				}
+				costDir, newCostDir := dists.At(iDir, jDir), entry.dist+*cost
+				if costDir < -0.5 || newCostDir < costDir {
+					dists.SetAt(iDir, jDir, newCostDir)
+					stats.relaxed()
+					queue.push(common.Position{I: iDir, J: jDir}, newCostDir)
+				}
+			}
+		}
+	}
+	return dists, dists.Flush()
+}
+
+// NewOutOfCoreDijkstra wraps OutOfCoreDijkstra into a Solver, the labels are
+// copied into memory once the search is over
+func NewOutOfCoreDijkstra(options OutOfCoreOptions) Solver {
+	return func(field *Field, from, to common.Position, stats *Stats) *internal.HeightMap {
+		options := options
+		if options.Dir == "" {
+			dir, err := os.MkdirTemp("", "dijkstra")
+			if err != nil {
+				log.WithError(err).Panic("failed to create the directory for the tiles")
+			}
+			defer os.RemoveAll(dir)
+			options.Dir = dir
+		}
+		dists, err := OutOfCoreDijkstra(field, from, to, options, stats)
+		if err != nil {
+			log.WithError(err).Panic("failed to store the tiles")
+		}
+		return internal.InMemory(dists)
+	}
+}
@@ -0,0 +1,119 @@
+package algorithms
+
+import (
+	"fmt"
+	"terrain/internal/common"
+)
+
+// moveDirection returns the direction to the neighbour, ok is false if the cells are not neighbours
+func moveDirection(from, to common.Position) (dir Direction, ok bool) {
+	for dir = 0; int(dir) < DirectionCount; dir++ {
+		if i, j := DirectionToIndexes(from.I, from.J, dir); i == to.I && j == to.J {
+			return dir, true
+		}
+	}
+	return -1, false
+}
+
+// MoveCost returns the cost of moving between the neighbouring cells, nil if the move is illegal
+func (field *Field) MoveCost(from, to common.Position) *float32 {
+	dir, ok := moveDirection(from, to)
+	if !ok || !field.IsValidIndex(to.I, to.J) {
+		return nil
+	}
+	return field.Length(to.I, to.J, dir.Opposite())
+}
+
+// PathViolation describes the first illegal step of a path
+type PathViolation struct {
+	// Step is the index of the cell the illegal move leads to
+	Step   int
+	Cell   common.Position
+	Reason string
+}
+
+func (violation *PathViolation) Error() string {
+	return fmt.Sprintf("step %d to (%d, %d): %s", violation.Step, violation.Cell.I, violation.Cell.J, violation.Reason)
+}
+
+// cellViolation explains why the vehicle may not stay at the cell, it is empty
+// if it may. The goal may be impassable, see Field.Length
+func (field *Field) cellViolation(pos common.Position, goal bool) string {
+	iMax, jMax := field.Bounds()
+	switch {
+	case pos.I < 0 || pos.J < 0 || pos.I >= iMax || pos.J >= jMax:
+		return "the cell is out of the field"
+	case goal:
+	case field.isObstacle(pos.I, pos.J):
+		return "the cell is impassable"
+	case !field.IsValidIndex(pos.I, pos.J):
+		return "the cell is too close to an obstacle for the footprint"
+	}
+	return ""
+}
+
+// ValidatePath sums the costs of the moves along the path and
+// reports the first illegal step if there is one. The last cell is the goal
+// and may be impassable, see Field.Length
+func (field *Field) ValidatePath(path []common.Position) (cost float32, violation *PathViolation) {
+	if len(path) != 0 {
+		if reason := field.cellViolation(path[0], false); reason != "" {
+			return 0, &PathViolation{Step: 0, Cell: path[0], Reason: reason}
+		}
+	}
+	for k := 0; k+1 < len(path); k++ {
+		dir, ok := moveDirection(path[k], path[k+1])
+		if !ok {
+			return cost, &PathViolation{Step: k + 1, Cell: path[k+1], Reason: "the cell is not a neighbour of the previous one"}
+		}
+		if reason := field.cellViolation(path[k+1], k+2 == len(path)); reason != "" {
+			return cost, &PathViolation{Step: k + 1, Cell: path[k+1], Reason: reason}
+		}
+		move := field.Length(path[k+1].I, path[k+1].J, dir.Opposite())
+		if move == nil {
+			return cost, &PathViolation{Step: k + 1, Cell: path[k+1], Reason: "the slope is too steep"}
+		}
+		cost += *move
+	}
+	return cost, nil
+}
+
+// PathCost sums the costs of the moves along the path, ok is false if any of
+// the moves is illegal
+func (field *Field) PathCost(path []common.Position) (cost float32, ok bool) {
+	cost, violation := field.ValidatePath(path)
+	if violation != nil {
+		return 0, false
+	}
+	return cost, true
+}
+
+// Line returns the 8-connected cells of the straight line between the
+// positions with the Bresenham algorithm, both ends included
+func Line(from, to common.Position) (cells []common.Position) {
+	di, dj := to.I-from.I, to.J-from.J
+	si, sj := 1, 1
+	if di < 0 {
+		di, si = -di, -1
+	}
+	if dj < 0 {
+		dj, sj = -dj, -1
+	}
+	cells = make([]common.Position, 0, di+dj+1)
+	err := di - dj
+	for pos := from; ; {
+		cells = append(cells, pos)
+		if pos == to {
+			return
+		}
+		e2 := 2 * err
+		if e2 > -dj {
+			err -= dj
+			pos.I += si
+		}
+		if e2 < di {
+			err += di
+			pos.J += sj
+		}
+	}
+}
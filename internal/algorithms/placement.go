@@ -0,0 +1,175 @@
+package algorithms
+
+import (
+	"runtime"
+	"terrain/internal"
+	"terrain/internal/common"
+)
+
+// Objective tells how the travel costs to the demand cells are aggregated
+type Objective int
+
+const (
+	MaxCostObjective Objective = iota
+	MeanCostObjective
+)
+
+// Placement is a set of depots with the quality of the service they provide
+type Placement struct {
+	Depots []common.Position
+	// Uncovered is the number of demand cells no depot reaches
+	Uncovered int
+	Cost      float32
+}
+
+func (placement Placement) betterThan(other Placement) bool {
+	return placement.Uncovered < other.Uncovered ||
+		(placement.Uncovered == other.Uncovered && placement.Cost < other.Cost)
+}
+
+// CellsWithColor returns all the cells of the texture painted with the colour
+func (field *Field) CellsWithColor(c common.HexColor) (cells []common.Position) {
+	iMax, jMax := field.Bounds()
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			if c.Matches(field.RGBA.RGBAAt(i, j)) {
+				cells = append(cells, common.Position{I: i, J: j})
+			}
+		}
+	}
+	return
+}
+
+// PassableCells returns all the cells the vehicle may stay at
+func (field *Field) PassableCells() (cells []common.Position) {
+	iMax, jMax := field.Bounds()
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			if field.IsValidIndex(i, j) {
+				cells = append(cells, common.Position{I: i, J: j})
+			}
+		}
+	}
+	return
+}
+
+type placementProblem struct {
+	// costs[c][d] is the travel cost from the candidate c to the demand cell d, -1 if unreachable
+	costs     [][]float32
+	objective Objective
+}
+
+func (problem *placementProblem) evaluate(depots []int) (placement Placement) {
+	demandCount := len(problem.costs[depots[0]])
+	for d := 0; d < demandCount; d++ {
+		best := float32(-1)
+		for _, c := range depots {
+			if cost := problem.costs[c][d]; cost > -0.5 && (best < -0.5 || cost < best) {
+				best = cost
+			}
+		}
+		switch {
+		case best < -0.5:
+			placement.Uncovered++
+		case problem.objective == MaxCostObjective && best > placement.Cost:
+			placement.Cost = best
+		case problem.objective == MeanCostObjective:
+			placement.Cost += best
+		}
+	}
+	if covered := demandCount - placement.Uncovered; problem.objective == MeanCostObjective && covered != 0 {
+		placement.Cost /= float32(covered)
+	}
+	return
+}
+
+// bestOf evaluates the variants in parallel and returns the index of the best one
+func (problem *placementProblem) bestOf(variants [][]int) (best int, placement Placement) {
+	numCPU := runtime.NumCPU()
+	results := make([]Placement, len(variants))
+	internal.ParallelFor(0, numCPU, 1, func(batchNum int) {
+		for v := batchNum; v < len(variants); v += numCPU {
+			results[v] = problem.evaluate(variants[v])
+		}
+	})
+	best = -1
+	for v, result := range results {
+		if best == -1 || result.betterThan(placement) {
+			best, placement = v, result
+		}
+	}
+	return
+}
+
+// PlaceFacilities chooses k depots among the candidates so that the worst or
+// the mean travel cost to the demand cells is minimal. The depots are picked
+// greedily one by one and then improved by swapping them with other candidates
+// while it helps
+func PlaceFacilities(field *Field, candidates, demand []common.Position, k int, objective Objective) (placement Placement) {
+	if k > len(candidates) {
+		k = len(candidates)
+	}
+	if k <= 0 || len(demand) == 0 {
+		return Placement{Depots: make([]common.Position, 0), Uncovered: len(demand)}
+	}
+
+	problem := &placementProblem{costs: make([][]float32, len(candidates)), objective: objective}
+	numCPU := runtime.NumCPU()
+	internal.ParallelFor(0, numCPU, 1, func(batchNum int) {
+		for c := batchNum; c < len(candidates); c += numCPU {
+			dists := NewCostField(field, Outbound, candidates[c])
+			problem.costs[c] = make([]float32, len(demand))
+			for d, pos := range demand {
+				problem.costs[c][d] = dists.At(pos.I, pos.J)
+			}
+		}
+	})
+
+	selected := make([]int, 0, k)
+	isSelected := make([]bool, len(candidates))
+	for len(selected) < k {
+		variants, added := make([][]int, 0), make([]int, 0)
+		for c := range candidates {
+			if !isSelected[c] {
+				variants = append(variants, append(append([]int{}, selected...), c))
+				added = append(added, c)
+			}
+		}
+		best, _ := problem.bestOf(variants)
+		selected, isSelected[added[best]] = variants[best], true
+	}
+
+	placement = problem.evaluate(selected)
+	for improved := true; improved; {
+		improved = false
+		variants := make([][]int, 0)
+		for slot := range selected {
+			for c := range candidates {
+				if isSelected[c] {
+					continue
+				}
+				variant := append([]int{}, selected...)
+				variant[slot] = c
+				variants = append(variants, variant)
+			}
+		}
+		if len(variants) == 0 {
+			break
+		}
+		if best, result := problem.bestOf(variants); result.betterThan(placement) {
+			for _, c := range selected {
+				isSelected[c] = false
+			}
+			selected, placement, improved = variants[best], result, true
+			for _, c := range selected {
+				isSelected[c] = true
+			}
+		}
+	}
+
+	placement.Depots = make([]common.Position, len(selected))
+	for idx, c := range selected {
+		placement.Depots[idx] = candidates[c]
+	}
+	return
+}
@@ -0,0 +1,47 @@
+package algorithms
+
+import (
+	"math"
+	"terrain/internal/common"
+	"testing"
+)
+
+func TestPlaceOneMatchesExhaustive(t *testing.T) {
+	field := randomField(8, 14, 12, 0.15)
+	demand := field.PassableCells()
+	var candidates []common.Position
+	for idx := 0; idx < len(demand); idx += 3 {
+		candidates = append(candidates, demand[idx])
+	}
+	for _, objective := range []Objective{MaxCostObjective, MeanCostObjective} {
+		// Every candidate alone, the fewest uncovered cells and then the lowest cost wins
+		var best Placement
+		for idx, candidate := range candidates {
+			dists := NewCostField(field, Outbound, candidate)
+			placement := Placement{Depots: []common.Position{candidate}}
+			covered := 0
+			for _, pos := range demand {
+				dist := dists.At(pos.I, pos.J)
+				switch {
+				case dist < -0.5:
+					placement.Uncovered++
+				case objective == MaxCostObjective:
+					placement.Cost = float32(math.Max(float64(placement.Cost), float64(dist)))
+				default:
+					placement.Cost += dist
+					covered++
+				}
+			}
+			if covered != 0 {
+				placement.Cost /= float32(covered)
+			}
+			if idx == 0 || placement.betterThan(best) {
+				best = placement
+			}
+		}
+		placement := PlaceFacilities(field, candidates, demand, 1, objective)
+		if len(placement.Depots) != 1 || placement.Uncovered != best.Uncovered || math.Abs(float64(placement.Cost-best.Cost)) > 1e-3*float64(best.Cost) {
+			t.Errorf("objective %d: placed %+v instead of %+v", objective, placement, best)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package algorithms
+
+import (
+	"encoding/json"
+	"image/color"
+	"io/ioutil"
+	"math"
+	"terrain/internal/common"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TerrainClass is a kind of soil painted on the texture with its own colour
+type TerrainClass struct {
+	Name  string
+	Color common.HexColor
+	// Speed is relative to the unlisted colours, 0 makes the class impassable
+	Speed float32
+}
+
+// Profile describes the vehicle (or the hiker) the path is planned for
+type Profile struct {
+	Name string
+	// StepCost is the cost of moving to a neighbour cell of an unlisted colour on a flat ground
+	StepCost float32
+	// UphillCost is the cost of climbing a unit of height
+	UphillCost float32
+	// MaxSlope is the steepest climbable slope in degrees, 0 means there is no limit
+	MaxSlope float32
+	Classes  []TerrainClass
+	// FootprintRadius is the radius of the vehicle in height map units, the
+	// cells closer to an obstacle are impassable
+	FootprintRadius float32
+	// ProximityCost is added to the steps right next to an obstacle and fades
+	// out linearly by the ProximityDistance
+	ProximityCost     float32
+	ProximityDistance float32
+}
+
+// DefaultProfile reproduces the costs the solvers have always used on the
+// unlisted colours. It knows the water and the marsh the hydrology and the
+// texture rules paint, so the rivers and the lakes stay impassable
+func DefaultProfile() *Profile {
+	return &Profile{
+		Name:       "default",
+		StepCost:   requireCost,
+		UphillCost: 100,
+		Classes: []TerrainClass{
+			{Name: "marsh", Color: common.HexColor{G: 0xff, B: 0xff, A: 0xff}, Speed: 0.5},
+			{Name: "water", Color: common.HexColor{B: 0xff, A: 0xff}, Speed: 0},
+		},
+	}
+}
+
+func LoadProfile(filePath string) (profile *Profile) {
+	file, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.WithError(err).Panic("failed to load profile from file")
+	}
+	profile = DefaultProfile()
+	profile.Name = ""
+	if err = json.Unmarshal(file, profile); err != nil {
+		log.WithError(err).Panic("failed to load profile from file")
+	}
+	return
+}
+
+// speed returns the relative speed on the colour, 0 if it is impassable
+func (profile *Profile) speed(c color.RGBA) float32 {
+	for _, class := range profile.Classes {
+		if class.Color.Matches(c) {
+			return class.Speed
+		}
+	}
+	return 1
+}
+
+// minStepCost is the cheapest possible move, the planners use it for their heuristics
+func (profile *Profile) minStepCost() float32 {
+	maxSpeed := float32(1)
+	for _, class := range profile.Classes {
+		if class.Speed > maxSpeed {
+			maxSpeed = class.Speed
+		}
+	}
+	return profile.StepCost / maxSpeed
+}
+
+// maxRise returns the largest climbable rise over the horizontal distance, +Inf if there is no limit
+func (profile *Profile) maxRise(distance float32) float32 {
+	if profile.MaxSlope <= 0 {
+		return inf
+	}
+	return distance * float32(math.Tan(float64(profile.MaxSlope)*math.Pi/180))
+}
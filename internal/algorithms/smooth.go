@@ -0,0 +1,183 @@
+package algorithms
+
+import (
+	"math"
+	"terrain/internal/common"
+)
+
+type SmoothOptions struct {
+	// Tolerance is the relative cost increase of the smoothed path. Every part
+	// of it is compared with the part of the original path it replaces, so the
+	// stages do not add up their increases
+	Tolerance float32
+	// Epsilon is the Douglas-Peucker distance in cells, 0 disables the simplification
+	Epsilon float64
+	// Spline enables the Catmull-Rom spline fitting through the waypoints
+	Spline bool
+}
+
+// SmoothedPath keeps the smoothed polyline together with the grid path it follows
+type SmoothedPath struct {
+	// Waypoints is the polyline in cell coordinates
+	Waypoints [][2]float64
+	// Cells is the path of legal neighbour moves along the polyline
+	Cells []common.Position
+	Cost  float32
+}
+
+type smoother struct {
+	field   *Field
+	options SmoothOptions
+}
+
+func (s *smoother) cost(cells []common.Position) float32 {
+	cost, ok := s.field.PathCost(cells)
+	if !ok {
+		return inf
+	}
+	return cost
+}
+
+// accept tells whether the cells may replace the original part of the path
+func (s *smoother) accept(cells, original []common.Position) bool {
+	cost := s.cost(cells)
+	return cost != inf && cost <= s.cost(original)*(1+s.options.Tolerance)
+}
+
+// pull shortcuts the path by the straight lines of sight and returns the
+// indexes of the remaining waypoints
+func (s *smoother) pull(path []common.Position) (waypoints []int) {
+	waypoints = []int{0}
+	for anchor := 0; anchor < len(path)-1; {
+		next := anchor + 1
+		for k := anchor + 2; k < len(path); k++ {
+			if !s.accept(Line(path[anchor], path[k]), path[anchor:k+1]) {
+				break
+			}
+			next = k
+		}
+		waypoints = append(waypoints, next)
+		anchor = next
+	}
+	return
+}
+
+func distanceToSegment(p, a, b common.Position) float64 {
+	dx, dy := float64(b.I-a.I), float64(b.J-a.J)
+	px, py := float64(p.I-a.I), float64(p.J-a.J)
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return math.Hypot(px, py)
+	}
+	return math.Abs(dx*py-dy*px) / length
+}
+
+// simplify drops the waypoints closer than epsilon to the line between their neighbours
+func (s *smoother) simplify(path []common.Position, waypoints []int) []int {
+	if len(waypoints) < 3 {
+		return waypoints
+	}
+	first, last := waypoints[0], waypoints[len(waypoints)-1]
+	farthest, distance := 0, -1.
+	for k := 1; k < len(waypoints)-1; k++ {
+		if d := distanceToSegment(path[waypoints[k]], path[first], path[last]); d > distance {
+			farthest, distance = k, d
+		}
+	}
+	if distance <= s.options.Epsilon && s.accept(Line(path[first], path[last]), path[first:last+1]) {
+		return []int{first, last}
+	}
+	head := s.simplify(path, waypoints[:farthest+1])
+	tail := s.simplify(path, waypoints[farthest:])
+	return append(head, tail[1:]...)
+}
+
+// follow joins the waypoints with straight lines
+func (s *smoother) follow(path []common.Position, waypoints []int) (cells []common.Position) {
+	cells = []common.Position{path[waypoints[0]]}
+	for k := 0; k+1 < len(waypoints); k++ {
+		cells = append(cells, Line(path[waypoints[k]], path[waypoints[k+1]])[1:]...)
+	}
+	return
+}
+
+func catmullRom(p0, p1, p2, p3 [2]float64, t float64) (point [2]float64) {
+	t2, t3 := t*t, t*t*t
+	for c := range point {
+		point[c] = 0.5 * (2*p1[c] + (p2[c]-p0[c])*t +
+			(2*p0[c]-5*p1[c]+4*p2[c]-p3[c])*t2 +
+			(3*p1[c]-p0[c]-3*p2[c]+p3[c])*t3)
+	}
+	return
+}
+
+// rasterize joins the cells under the points into a path of neighbour moves
+func rasterize(points [][2]float64) (cells []common.Position) {
+	for _, point := range points {
+		pos := common.Position{I: int(math.Round(point[0])), J: int(math.Round(point[1]))}
+		if len(cells) == 0 {
+			cells = append(cells, pos)
+			continue
+		}
+		if last := cells[len(cells)-1]; last != pos {
+			cells = append(cells, Line(last, pos)[1:]...)
+		}
+	}
+	return
+}
+
+// SmoothPath turns the staircase path of a solver into a shorter polyline.
+// Every step is validated against the field: it is rejected if it crosses an
+// impassable cell or costs more than the tolerance allows over the original
+// cells it replaces
+func SmoothPath(field *Field, path []common.Position, options SmoothOptions) (smoothed *SmoothedPath) {
+	s := &smoother{field: field, options: options}
+	smoothed = new(SmoothedPath)
+	if len(path) < 2 {
+		smoothed.Cells = append([]common.Position{}, path...)
+		for _, pos := range path {
+			smoothed.Waypoints = append(smoothed.Waypoints, [2]float64{float64(pos.I), float64(pos.J)})
+		}
+		return
+	}
+
+	waypoints := s.pull(path)
+	if options.Epsilon > 0 {
+		waypoints = s.simplify(path, waypoints)
+	}
+	smoothed.Cells = s.follow(path, waypoints)
+	for _, k := range waypoints {
+		smoothed.Waypoints = append(smoothed.Waypoints, [2]float64{float64(path[k].I), float64(path[k].J)})
+	}
+
+	if options.Spline && len(waypoints) > 2 {
+		points := [][2]float64{smoothed.Waypoints[0]}
+		cells := []common.Position{path[waypoints[0]]}
+		for k := 0; k+1 < len(waypoints); k++ {
+			p0, p3 := smoothed.Waypoints[k], smoothed.Waypoints[k+1]
+			if k > 0 {
+				p0 = smoothed.Waypoints[k-1]
+			}
+			if k+2 < len(waypoints) {
+				p3 = smoothed.Waypoints[k+2]
+			}
+			p1, p2 := smoothed.Waypoints[k], smoothed.Waypoints[k+1]
+			// Sample the span about twice per cell
+			samples := int(2*math.Hypot(p2[0]-p1[0], p2[1]-p1[1])) + 1
+			span := [][2]float64{p1}
+			for n := 1; n <= samples; n++ {
+				span = append(span, catmullRom(p0, p1, p2, p3, float64(n)/float64(samples)))
+			}
+			spanCells := rasterize(span)
+			straight := Line(path[waypoints[k]], path[waypoints[k+1]])
+			if !s.accept(spanCells, path[waypoints[k]:waypoints[k+1]+1]) {
+				span, spanCells = [][2]float64{p1, p2}, straight
+			}
+			points = append(points, span[1:]...)
+			cells = append(cells, spanCells[1:]...)
+		}
+		smoothed.Waypoints, smoothed.Cells = points, cells
+	}
+	smoothed.Cost, _ = field.PathCost(smoothed.Cells)
+	return
+}
@@ -0,0 +1,37 @@
+package algorithms
+
+import (
+	"terrain/internal/common"
+	"testing"
+)
+
+func TestSmoothPathBound(t *testing.T) {
+	options := SmoothOptions{Tolerance: 0.3, Epsilon: 6, Spline: true}
+	for seed := int64(0); seed < 100; seed++ {
+		field := randomField(seed, 40, 30, 0.1)
+		from, to := common.Position{I: 1, J: 1}, common.Position{I: 38, J: 27}
+		if !field.IsValidIndex(from.I, from.J) {
+			continue
+		}
+		path := TracePath(field, Dijkstra(field, from, to, nil), from, to)
+		if path == nil {
+			continue
+		}
+		cost, violation := field.ValidatePath(path)
+		if violation != nil {
+			t.Fatalf("seed %d: the solver path is illegal: %v", seed, violation)
+		}
+		smoothed := SmoothPath(field, path, options)
+		if smoothed.Cells[0] != from || smoothed.Cells[len(smoothed.Cells)-1] != to {
+			t.Errorf("seed %d: the smoothed path goes from %v to %v", seed, smoothed.Cells[0], smoothed.Cells[len(smoothed.Cells)-1])
+		}
+		// The tolerance bounds the whole path once, not every stage
+		smoothedCost, violation := field.ValidatePath(smoothed.Cells)
+		if violation != nil {
+			t.Fatalf("seed %d: the smoothed path is illegal: %v", seed, violation)
+		}
+		if smoothedCost > cost*(1+options.Tolerance)*(1+1e-5) || smoothed.Cost != smoothedCost {
+			t.Errorf("seed %d: the smoothed path costs %g (%g) over %g of the original", seed, smoothedCost, smoothed.Cost, cost)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package algorithms
+
+import (
+	"sync/atomic"
+	"terrain/internal"
+	"terrain/internal/common"
+	"time"
+)
+
+// Solver fills dists with the cost of reaching `to` from the cells it has
+// explored, at least from `from` if it is reachable. Unreachable cells are -1.
+// Stats may be nil
+type Solver func(field *Field, from, to common.Position, stats *Stats) (dists *internal.HeightMap)
+
+// Stats collects the counters of a running solver
+type Stats struct {
+	// Progress is called on every iteration of the main loop, may be nil
+	Progress   func()
+	Iterations int64
+	// Relaxed is the number of times a label has been improved
+	Relaxed int64
+	// Network is the time the master has been waiting for the followers
+	Network time.Duration
+}
+
+func (stats *Stats) step() {
+	if stats == nil {
+		return
+	}
+	stats.Iterations++
+	if stats.Progress != nil {
+		stats.Progress()
+	}
+}
+
+// relaxed may be called from several goroutines at once
+func (stats *Stats) relaxed() {
+	if stats != nil {
+		atomic.AddInt64(&stats.Relaxed, 1)
+	}
+}
+
+// newDists prepares the labels: zero for the target and inf as -1 for the rest
+func newDists(field *Field, to common.Position) (dists *internal.HeightMap) {
+	iMax, jMax := field.Bounds()
+	dists = internal.EmptyHeightMap(iMax, jMax)
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			dists.SetAt(i, j, float32(-1))
+		}
+	}
+	dists.SetAt(to.I, to.J, 0)
+	return
+}
+
+// TracePath follows the labels from `from` to `to`, nil if `to` is unreachable
+func TracePath(field *Field, dists internal.Grid, from, to common.Position) (result []common.Position) {
+	if dists.At(from.I, from.J) < -0.5 {
+		return nil
+	}
+	iMax, jMax := field.Bounds()
+	result = make([]common.Position, 0)
+	for i, j := from.I, from.J; i != to.I || j != to.J; {
+		result = append(result, common.Position{I: i, J: j})
+		minDist, minPosition := float32(-1), common.Position{}
+		for dir := 0; dir < DirectionCount; dir++ {
+			iDir, jDir := DirectionToIndexes(i, j, Direction(dir))
+			if iDir < 0 || jDir < 0 || iDir >= iMax || jDir >= jMax {
+				continue
+			}
+			// The target may be impassable, see Field.Length
+			if !field.IsValidIndex(iDir, jDir) && (iDir != to.I || jDir != to.J) {
+				continue
+			}
+			dist, cost := dists.At(iDir, jDir), field.Length(iDir, jDir, Direction(dir).Opposite())
+			if dist < -0.5 || cost == nil {
+				continue
+			}
+			if minDist < -0.5 || dist+*cost < minDist {
+				minDist, minPosition = dist+*cost, common.Position{I: iDir, J: jDir}
+			}
+		}
+		if minDist < -0.5 || len(result) > iMax*jMax {
+			return nil
+		}
+		i, j = minPosition.I, minPosition.J
+	}
+	return append(result, to)
+}
@@ -0,0 +1,240 @@
+package algorithms
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"terrain/internal"
+	"terrain/internal/common"
+	"testing"
+)
+
+var (
+	white = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	black = color.RGBA{A: 0xff}
+)
+
+// randomField generates a smooth seeded height map and scatters obstacles over it
+func randomField(seed int64, iMax, jMax int, obstacles float64) *Field {
+	r := rand.New(rand.NewSource(seed))
+	heights := internal.EmptyHeightMap(iMax, jMax)
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			heights.SetAt(i, j, r.Float32())
+		}
+	}
+	// A pass of box blur makes the slopes look like a terrain instead of a noise
+	blurred := internal.EmptyHeightMap(iMax, jMax)
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			sum, count := float32(0), 0
+			for di := -1; di <= 1; di++ {
+				for dj := -1; dj <= 1; dj++ {
+					if i+di >= 0 && j+dj >= 0 && i+di < iMax && j+dj < jMax {
+						sum, count = sum+heights.At(i+di, j+dj), count+1
+					}
+				}
+			}
+			blurred.SetAt(i, j, sum/float32(count))
+		}
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, iMax, jMax))
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			rgba.SetRGBA(i, j, white)
+			if r.Float64() < obstacles {
+				rgba.SetRGBA(i, j, black)
+			}
+		}
+	}
+	return NewField(blurred, rgba)
+}
+
+type solverCase struct {
+	name   string
+	solver Solver
+}
+
+func allSolvers(t *testing.T) []solverCase {
+	followers := make([]string, 3)
+	for idx := range followers {
+		server := httptest.NewServer(NewFollower())
+		t.Cleanup(server.Close)
+		followers[idx] = server.URL
+	}
+	return []solverCase{
+		{"BellmanFord", BellmanFord},
+		{"ParallelBellmanFord", ParallelBellmanFord},
+		{"Dijkstra", Dijkstra},
+		{"ParallelDijkstra", ParallelDijkstra},
+		{"DistributedDijkstra", NewDistributedDijkstra(followers, &http.Client{})},
+		{"DStarLite", SolveDStarLite},
+		{"OutOfCoreDijkstra", NewOutOfCoreDijkstra(OutOfCoreOptions{TileSize: 4, CacheSize: 2})},
+	}
+}
+
+func almostEqual(a, b float32) bool {
+	return math.Abs(float64(a-b)) <= 1e-4*math.Max(1, math.Abs(float64(b)))
+}
+
+type solverScenario struct {
+	name     string
+	field    *Field
+	from, to common.Position
+}
+
+func randomScenarios() (scenarios []solverScenario) {
+	for seed := int64(1); seed <= 12; seed++ {
+		r := rand.New(rand.NewSource(seed * 7919))
+		iMax, jMax := 4+r.Intn(9), 4+r.Intn(9)
+		field := randomField(seed, iMax, jMax, 0.2)
+		from := common.Position{I: r.Intn(iMax), J: r.Intn(jMax)}
+		to := common.Position{I: r.Intn(iMax), J: r.Intn(jMax)}
+		field.RGBA.SetRGBA(from.I, from.J, white)
+		field.RGBA.SetRGBA(to.I, to.J, white)
+		scenarios = append(scenarios, solverScenario{fmt.Sprintf("seed %d %dx%d", seed, iMax, jMax), field, from, to})
+	}
+
+	field := randomField(100, 7, 7, 0)
+	scenarios = append(scenarios, solverScenario{"start equals goal", field, common.Position{I: 3, J: 3}, common.Position{I: 3, J: 3}})
+
+	// The goal is walled in
+	field = randomField(101, 9, 9, 0)
+	for i := 3; i <= 5; i++ {
+		for j := 3; j <= 5; j++ {
+			if i != 4 || j != 4 {
+				field.RGBA.SetRGBA(i, j, black)
+			}
+		}
+	}
+	scenarios = append(scenarios, solverScenario{"unreachable goal", field, common.Position{I: 0, J: 0}, common.Position{I: 4, J: 4}})
+
+	// The solvers reach a goal painted black and the path is still valid
+	field = randomField(102, 8, 8, 0)
+	field.RGBA.SetRGBA(6, 5, black)
+	scenarios = append(scenarios, solverScenario{"black goal", field, common.Position{I: 1, J: 0}, common.Position{I: 6, J: 5}})
+	return
+}
+
+func TestSolversAgree(t *testing.T) {
+	solvers := allSolvers(t)
+	for _, scenario := range randomScenarios() {
+		t.Run(scenario.name, func(t *testing.T) {
+			field, from, to := scenario.field, scenario.from, scenario.to
+			want := NewCostField(field, Inbound, to).At(from.I, from.J)
+
+			for _, solver := range solvers {
+				dists := solver.solver(field, from, to, nil)
+				got := dists.At(from.I, from.J)
+				if (want < -0.5) != (got < -0.5) || (want > -0.5 && !almostEqual(got, want)) {
+					t.Errorf("%s: cost %0.4f, want %0.4f", solver.name, got, want)
+					continue
+				}
+
+				path := TracePath(field, dists, from, to)
+				if want < -0.5 {
+					if path != nil {
+						t.Errorf("%s: path %v to the unreachable goal", solver.name, path)
+					}
+					continue
+				}
+				if len(path) == 0 || path[0] != from || path[len(path)-1] != to {
+					t.Errorf("%s: path %v does not lead from %v to %v", solver.name, path, from, to)
+					continue
+				}
+				cost, violation := field.ValidatePath(path)
+				if violation != nil {
+					t.Errorf("%s: invalid path: %s", solver.name, violation)
+				} else if !almostEqual(cost, want) {
+					t.Errorf("%s: path costs %0.4f, want %0.4f", solver.name, cost, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDStarLiteReplansAfterChanges(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	field := randomField(42, 12, 12, 0.1)
+	from, to := common.Position{I: 0, J: 0}, common.Position{I: 11, J: 11}
+	field.RGBA.SetRGBA(from.I, from.J, white)
+	field.RGBA.SetRGBA(to.I, to.J, white)
+
+	planner := NewDStarLite(field, from, to)
+	planner.Replan()
+	for revision := 0; revision < 20; revision++ {
+		want := NewCostField(field, Inbound, to).At(from.I, from.J)
+		if got := planner.Cost(); (want < -0.5) != (got < -0.5) || (want > -0.5 && !almostEqual(got, want)) {
+			t.Fatalf("revision %d: cost %0.4f, want %0.4f", revision, got, want)
+		}
+
+		cell := common.Position{I: r.Intn(12), J: r.Intn(12)}
+		if cell == from || cell == to {
+			continue
+		}
+		if r.Intn(2) == 0 {
+			field.RGBA.SetRGBA(cell.I, cell.J, black)
+		} else {
+			field.RGBA.SetRGBA(cell.I, cell.J, white)
+			field.HeightMap.SetAt(cell.I, cell.J, r.Float32())
+		}
+		planner.UpdateCells(cell)
+		planner.Replan()
+	}
+}
+
+func TestOutOfCoreDijkstraFollowsDijkstra(t *testing.T) {
+	for _, scenario := range randomScenarios() {
+		t.Run(scenario.name, func(t *testing.T) {
+			field, from, to := scenario.field, scenario.from, scenario.to
+			options := OutOfCoreOptions{Dir: t.TempDir(), TileSize: 3, CacheSize: 2}
+			dists, err := OutOfCoreDijkstra(field, from, to, options, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := TracePath(field, Dijkstra(field, from, to, nil), from, to)
+			got := TracePath(field, dists, from, to)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("path %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestOutOfCoreDijkstraReusesDir(t *testing.T) {
+	field := randomField(7, 12, 12, 0.1)
+	dir := t.TempDir()
+	// The labels of the earlier search must not leak into the later one
+	queries := [][2]common.Position{{{I: 0, J: 0}, {I: 11, J: 11}}, {{I: 11, J: 0}, {I: 0, J: 11}}, {{I: 0, J: 0}, {I: 11, J: 11}}}
+	for _, query := range queries {
+		from, to := query[0], query[1]
+		field.RGBA.SetRGBA(from.I, from.J, white)
+		field.RGBA.SetRGBA(to.I, to.J, white)
+		dists, err := OutOfCoreDijkstra(field, from, to, OutOfCoreOptions{Dir: dir, TileSize: 4, CacheSize: 2}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Dijkstra(field, from, to, nil).At(from.I, from.J)
+		if got := dists.At(from.I, from.J); !almostEqual(got, want) {
+			t.Errorf("from %v to %v: cost %0.4f, want %0.4f", from, to, got, want)
+		}
+	}
+}
+
+func TestDefaultProfileWater(t *testing.T) {
+	field := randomField(4, 5, 5, 0)
+	// The colours the hydrology paints the rivers and the marsh with
+	field.RGBA.SetRGBA(2, 2, color.RGBA{B: 0xff, A: 0xff})
+	field.RGBA.SetRGBA(3, 3, color.RGBA{G: 0xff, B: 0xff, A: 0xff})
+	if field.IsValidIndex(2, 2) {
+		t.Error("the water is passable under the default profile")
+	}
+	if !field.IsValidIndex(3, 3) || field.Profile.speed(field.RGBA.RGBAAt(3, 3)) >= 1 {
+		t.Error("the marsh is not slow under the default profile")
+	}
+}
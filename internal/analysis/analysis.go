@@ -0,0 +1,181 @@
+// Package analysis derives the terrain rasters the cost models are built on.
+// The rasters have the size of the height map, the cells on the edges repeat
+// the outer samples and the cells next to no data have no data themselves
+package analysis
+
+import (
+	"math"
+	"terrain/internal"
+)
+
+// NoData marks the cells the rasters can not be computed for
+const NoData float32 = -9999
+
+// FlatAspect is the aspect of the cells without a slope
+const FlatAspect float32 = -1
+
+// Kind is a derived raster
+type Kind string
+
+const (
+	// SlopeKind is the steepest slope in degrees
+	SlopeKind Kind = "slope"
+	// AspectKind is the compass direction the slope faces in degrees clockwise from the north
+	AspectKind Kind = "aspect"
+	// PlanCurvatureKind is the curvature across the slope, positive on the ridges
+	PlanCurvatureKind Kind = "plan-curvature"
+	// ProfileCurvatureKind is the curvature along the slope, positive where it steepens downhill
+	ProfileCurvatureKind Kind = "profile-curvature"
+	// RuggednessKind is the terrain ruggedness index of Riley
+	RuggednessKind Kind = "ruggedness"
+)
+
+// Kinds lists the rasters in the order they are usually reported
+var Kinds = []Kind{SlopeKind, AspectKind, PlanCurvatureKind, ProfileCurvatureKind, RuggednessKind}
+
+// window keeps the 3x3 neighbourhood of a cell, the rows go from the north
+// (j - 1) to the south and the columns from the west (i - 1) to the east
+type window [3][3]float64
+
+// sample reads the neighbourhood, ok is false if any of it has no data
+func sample(heights internal.Grid, i, j int) (w window, ok bool) {
+	iMax, jMax := heights.Bounds()
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			ni, nj := clamp(i+col-1, iMax), clamp(j+row-1, jMax)
+			if heights.IsNoData(ni, nj) {
+				return w, false
+			}
+			w[row][col] = float64(heights.At(ni, nj))
+		}
+	}
+	return w, true
+}
+
+func clamp(value, max int) int {
+	if value < 0 {
+		return 0
+	}
+	if value >= max {
+		return max - 1
+	}
+	return value
+}
+
+// spacing is the distance between the cells towards the east and towards the
+// north, they differ on the maps in degrees
+type spacing struct {
+	east, north float64
+}
+
+// horn returns the gradient of Horn towards the east and towards the north
+func (w window) horn(spacing spacing) (east, north float64) {
+	east = ((w[0][2] + 2*w[1][2] + w[2][2]) - (w[0][0] + 2*w[1][0] + w[2][0])) / (8 * spacing.east)
+	north = ((w[0][0] + 2*w[0][1] + w[0][2]) - (w[2][0] + 2*w[2][1] + w[2][2])) / (8 * spacing.north)
+	return
+}
+
+func (w window) slope(spacing spacing) float64 {
+	east, north := w.horn(spacing)
+	return math.Atan(math.Hypot(east, north)) * 180 / math.Pi
+}
+
+func (w window) aspect(spacing spacing) float64 {
+	east, north := w.horn(spacing)
+	if east == 0 && north == 0 {
+		return float64(FlatAspect)
+	}
+	// The slope faces down the gradient
+	aspect := math.Atan2(-east, -north) * 180 / math.Pi
+	if aspect < 0 {
+		aspect += 360
+	}
+	return aspect
+}
+
+// curvatures returns the plan and the profile curvatures of Zevenbergen and
+// Thorne in the inverse height units. The plan curvature is negated against
+// their paper, so the contours bending around a ridge give a positive value
+func (w window) curvatures(spacing spacing) (plan, profile float64) {
+	d := ((w[1][0]+w[1][2])/2 - w[1][1]) / (spacing.east * spacing.east)
+	e := ((w[0][1]+w[2][1])/2 - w[1][1]) / (spacing.north * spacing.north)
+	f := (-w[0][0] + w[0][2] + w[2][0] - w[2][2]) / (4 * spacing.east * spacing.north)
+	g := (w[1][2] - w[1][0]) / (2 * spacing.east)
+	h := (w[0][1] - w[2][1]) / (2 * spacing.north)
+	if g == 0 && h == 0 {
+		return 0, 0
+	}
+	plan = -2 * (d*h*h + e*g*g - f*g*h) / (g*g + h*h)
+	profile = -2 * (d*g*g + e*h*h + f*g*h) / (g*g + h*h)
+	return
+}
+
+func (w window) ruggedness() float64 {
+	sum := 0.
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			delta := w[row][col] - w[1][1]
+			sum += delta * delta
+		}
+	}
+	return math.Sqrt(sum)
+}
+
+// Compute derives the raster of the kind from the height map. The cells of
+// the maps in degrees are narrower towards the east than the north
+func Compute(heights internal.Grid, kind Kind) (raster *internal.HeightMap) {
+	spacing := spacing{float64(heights.Spacing()), float64(heights.Spacing())}
+	hm, inMemory := heights.(*internal.HeightMap)
+	if inMemory {
+		spacing.east = float64(hm.EastWestSpacing())
+	}
+	var value func(w window) float64
+	switch kind {
+	case SlopeKind:
+		value = func(w window) float64 { return w.slope(spacing) }
+	case AspectKind:
+		value = func(w window) float64 { return w.aspect(spacing) }
+	case PlanCurvatureKind:
+		value = func(w window) float64 { plan, _ := w.curvatures(spacing); return plan }
+	case ProfileCurvatureKind:
+		value = func(w window) float64 { _, profile := w.curvatures(spacing); return profile }
+	case RuggednessKind:
+		value = func(w window) float64 { return w.ruggedness() }
+	default:
+		return nil
+	}
+
+	iMax, jMax := heights.Bounds()
+	raster = internal.EmptyHeightMap(iMax, jMax)
+	raster.CellSize = heights.Spacing()
+	noData := NoData
+	raster.NoData = &noData
+	if inMemory {
+		raster.Origin, raster.DegreeStep = hm.Origin, hm.DegreeStep
+	}
+	internal.ParallelFor(0, iMax, 1, func(i int) {
+		for j := 0; j < jMax; j++ {
+			w, ok := sample(heights, i, j)
+			if !ok {
+				raster.SetAt(i, j, NoData)
+				continue
+			}
+			raster.SetAt(i, j, float32(value(w)))
+		}
+	})
+	return
+}
+
+func Slope(heights internal.Grid) *internal.HeightMap { return Compute(heights, SlopeKind) }
+
+func Aspect(heights internal.Grid) *internal.HeightMap { return Compute(heights, AspectKind) }
+
+func PlanCurvature(heights internal.Grid) *internal.HeightMap {
+	return Compute(heights, PlanCurvatureKind)
+}
+
+func ProfileCurvature(heights internal.Grid) *internal.HeightMap {
+	return Compute(heights, ProfileCurvatureKind)
+}
+
+func Ruggedness(heights internal.Grid) *internal.HeightMap { return Compute(heights, RuggednessKind) }
@@ -0,0 +1,79 @@
+package analysis
+
+import (
+	"math"
+	"terrain/internal"
+	"testing"
+)
+
+// surface samples the function on a 5x5 map with i to the east and j to the south
+func surface(height func(x, y float64) float64) *internal.HeightMap {
+	heights := internal.EmptyHeightMap(5, 5)
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 5; j++ {
+			heights.SetAt(i, j, float32(height(float64(i-2), float64(j-2))))
+		}
+	}
+	return heights
+}
+
+func TestRasters(t *testing.T) {
+	cases := []struct {
+		name    string
+		height  func(x, y float64) float64
+		kind    Kind
+		want    float32
+		epsilon float32
+	}{
+		// The plane rises to the east and to the north by a half per cell
+		{"plane slope", func(x, y float64) float64 { return 0.5*x - 0.5*y }, SlopeKind, 35.26439, 1e-4},
+		{"plane aspect", func(x, y float64) float64 { return 0.5*x - 0.5*y }, AspectKind, 225, 1e-4},
+		{"plane plan curvature", func(x, y float64) float64 { return 0.5*x - 0.5*y }, PlanCurvatureKind, 0, 1e-6},
+		{"plane profile curvature", func(x, y float64) float64 { return 0.5*x - 0.5*y }, ProfileCurvatureKind, 0, 1e-6},
+		{"flat aspect", func(x, y float64) float64 { return 3 }, AspectKind, FlatAspect, 0},
+		{"flat ruggedness", func(x, y float64) float64 { return 3 }, RuggednessKind, 0, 0},
+		// The ridge and the valley run north to south and descend to the north
+		{"ridge plan curvature", func(x, y float64) float64 { return -x*x + y }, PlanCurvatureKind, 2, 1e-4},
+		{"valley plan curvature", func(x, y float64) float64 { return x*x + y }, PlanCurvatureKind, -2, 1e-4},
+		// The slope to the east steepens downhill on the convex shoulder
+		{"convex profile curvature", func(x, y float64) float64 { return -x * x }, ProfileCurvatureKind, 2, 1e-4},
+		{"concave profile curvature", func(x, y float64) float64 { return x * x }, ProfileCurvatureKind, -2, 1e-4},
+		{"ridge ruggedness", func(x, y float64) float64 { return -x*x + y }, RuggednessKind, float32(math.Sqrt(12)), 1e-5},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			i, j := 2, 2
+			if c.kind == ProfileCurvatureKind && c.want != 0 {
+				// The centre of the parabola has no slope
+				i = 3
+			}
+			got := Compute(surface(c.height), c.kind).At(i, j)
+			if math.Abs(float64(got-c.want)) > float64(c.epsilon) {
+				t.Errorf("%s at (%d, %d) is %g, want %g", c.kind, i, j, got, c.want)
+			}
+		})
+	}
+}
+
+func TestNoData(t *testing.T) {
+	heights := surface(func(x, y float64) float64 { return x })
+	noData := float32(-1)
+	heights.NoData = &noData
+	heights.SetAt(0, 0, noData)
+	slope := Slope(heights)
+	if slope.At(1, 1) != NoData || slope.At(0, 0) != NoData {
+		t.Errorf("the cells next to no data have the slope %g and %g", slope.At(0, 0), slope.At(1, 1))
+	}
+	if slope.At(3, 3) == NoData {
+		t.Error("the cells away from no data have no data")
+	}
+}
+
+func TestDegrees(t *testing.T) {
+	// The cells at the latitude 60 are half as wide as they are high
+	heights := surface(func(x, y float64) float64 { return x })
+	heights.CellSize, heights.DegreeStep, heights.Origin = 2, 0.001, &[2]float64{10, 60.0025}
+	if got := Slope(heights).At(2, 2); math.Abs(float64(got-45)) > 1e-2 {
+		t.Errorf("the slope is %g instead of 45", got)
+	}
+}
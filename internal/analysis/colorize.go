@@ -0,0 +1,101 @@
+package analysis
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"terrain/internal"
+)
+
+// Ramp maps the values between Min and Max onto the evenly spaced colours
+type Ramp struct {
+	Colors []color.RGBA
+	Min    float32
+	Max    float32
+	// Cyclic ramps wrap around, the aspect one does
+	Cyclic bool
+}
+
+var (
+	slopeColors = []color.RGBA{{0x1a, 0x96, 0x41, 0xff}, {0xff, 0xff, 0xbf, 0xff}, {0xfd, 0xae, 0x61, 0xff}, {0xd7, 0x19, 0x1c, 0xff}}
+	// aspectColors go north, east, south, west and back to the north
+	aspectColors    = []color.RGBA{{0xe0, 0x30, 0x30, 0xff}, {0xe0, 0xd0, 0x30, 0xff}, {0x30, 0xb0, 0x50, 0xff}, {0x30, 0x60, 0xe0, 0xff}}
+	divergingColors = []color.RGBA{{0x21, 0x66, 0xac, 0xff}, {0xf7, 0xf7, 0xf7, 0xff}, {0xb2, 0x18, 0x2b, 0xff}}
+	heatColors      = []color.RGBA{{0x00, 0x00, 0x00, 0xff}, {0x80, 0x10, 0x60, 0xff}, {0xf0, 0x60, 0x20, 0xff}, {0xff, 0xff, 0xa0, 0xff}}
+)
+
+// NoDataColor paints the cells without data and the flat cells of the aspect
+var NoDataColor = color.RGBA{0x80, 0x80, 0x80, 0xff}
+
+// DefaultRamp returns the ramp of the kind. The curvatures are symmetric
+// around zero and the ruggedness starts from zero, both are stretched to the
+// largest value of the raster
+func DefaultRamp(kind Kind, raster *internal.HeightMap) Ramp {
+	switch kind {
+	case SlopeKind:
+		return Ramp{Colors: slopeColors, Min: 0, Max: 90}
+	case AspectKind:
+		return Ramp{Colors: aspectColors, Min: 0, Max: 360, Cyclic: true}
+	case PlanCurvatureKind, ProfileCurvatureKind:
+		limit := largest(raster, true)
+		return Ramp{Colors: divergingColors, Min: -limit, Max: limit}
+	default:
+		return Ramp{Colors: heatColors, Min: 0, Max: largest(raster, false)}
+	}
+}
+
+// largest returns the largest value or the largest absolute value of the raster
+func largest(raster *internal.HeightMap, absolute bool) (result float32) {
+	for _, value := range raster.Heights {
+		if value == NoData {
+			continue
+		}
+		if absolute && value < 0 {
+			value = -value
+		}
+		if value > result {
+			result = value
+		}
+	}
+	return
+}
+
+// At returns the colour of the value
+func (ramp Ramp) At(value float32) color.RGBA {
+	if ramp.Max <= ramp.Min || len(ramp.Colors) == 0 {
+		return NoDataColor
+	}
+	segments := len(ramp.Colors) - 1
+	if ramp.Cyclic {
+		segments++
+	}
+	t := float64((value - ramp.Min) / (ramp.Max - ramp.Min))
+	if ramp.Cyclic {
+		t -= math.Floor(t)
+	}
+	t = math.Max(0, math.Min(1, t)) * float64(segments)
+	idx := int(t)
+	if idx >= segments {
+		idx, t = segments-1, float64(segments)
+	}
+	from, to := ramp.Colors[idx], ramp.Colors[(idx+1)%len(ramp.Colors)]
+	mix := func(a, b uint8) uint8 { return uint8(float64(a) + (float64(b)-float64(a))*(t-float64(idx)) + 0.5) }
+	return color.RGBA{mix(from.R, to.R), mix(from.G, to.G), mix(from.B, to.B), 0xff}
+}
+
+// Colorize paints the raster with the ramp, pixel x is i and pixel y is j as in the textures
+func Colorize(raster *internal.HeightMap, ramp Ramp) (rgba *image.RGBA) {
+	iMax, jMax := raster.Bounds()
+	rgba = image.NewRGBA(image.Rect(0, 0, iMax, jMax))
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			value := raster.At(i, j)
+			if value == NoData || (ramp.Cyclic && value == FlatAspect) {
+				rgba.SetRGBA(i, j, NoDataColor)
+				continue
+			}
+			rgba.SetRGBA(i, j, ramp.At(value))
+		}
+	}
+	return
+}
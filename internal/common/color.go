@@ -0,0 +1,36 @@
+package common
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// HexColor is a colour written as "rrggbb" or "#rrggbb" in flags and configs
+type HexColor color.RGBA
+
+func (c *HexColor) UnmarshalText(text []byte) error {
+	value := strings.TrimPrefix(string(text), "#")
+	if _, err := fmt.Sscanf(value, "%02x%02x%02x", &c.R, &c.G, &c.B); err != nil || len(value) != 6 {
+		return fmt.Errorf("colour %q must look like #rrggbb", text)
+	}
+	c.A = 0xff
+	return nil
+}
+
+func (c HexColor) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)), nil
+}
+
+func (c *HexColor) UnmarshalFlag(value string) error {
+	return c.UnmarshalText([]byte(value))
+}
+
+// Matches compares the colour with a texture pixel ignoring the alpha channel
+func (c HexColor) Matches(other color.RGBA) bool {
+	return c.R == other.R && c.G == other.G && c.B == other.B
+}
+
+func (c HexColor) RGBA() color.RGBA {
+	return color.RGBA(c)
+}
@@ -0,0 +1,54 @@
+package common
+
+import (
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"os"
+)
+
+// GeoJSON is a minimal feature collection in the layout of RFC 7946. Its
+// coordinates are the longitude and the latitude the RFC asks for only if the
+// height map is in degrees, the projected maps keep their own coordinates and
+// the maps without a georeference the cells
+type GeoJSON struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   GeoJSONGeometry        `json:"geometry"`
+}
+
+type GeoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+func NewGeoJSON() *GeoJSON {
+	return &GeoJSON{Type: "FeatureCollection", Features: make([]GeoJSONFeature, 0)}
+}
+
+func (collection *GeoJSON) Add(geometryType string, coordinates interface{}, properties map[string]interface{}) {
+	collection.Features = append(collection.Features, GeoJSONFeature{
+		Type:       "Feature",
+		Properties: properties,
+		Geometry:   GeoJSONGeometry{Type: geometryType, Coordinates: coordinates},
+	})
+}
+
+func (collection *GeoJSON) FlushToFile(filePath string) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		log.WithError(err).Panic("Failed to open the destination file")
+	}
+	defer file.Close()
+	data, err := json.Marshal(collection)
+	if err != nil {
+		log.WithError(err).Panic("failed to marshal the collection")
+	}
+	if _, err = file.Write(data); err != nil {
+		log.WithError(err).Panic("failed to write the collection")
+	}
+}
@@ -4,7 +4,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"image"
 	"image/draw"
-	_ "image/png"
+	"image/png"
 	"os"
 )
 
@@ -27,3 +27,14 @@ func LoadRGBA(filePath string) *image.RGBA {
 
 	return rgba
 }
+
+func FlushRGBA(filePath string, rgba *image.RGBA) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		log.WithError(err).Panic("Failed to open the destination file")
+	}
+	defer file.Close()
+	if err = png.Encode(file, rgba); err != nil {
+		log.WithError(err).Panic("failed to encode image")
+	}
+}
@@ -1,5 +1,21 @@
 package common
 
+import (
+	"fmt"
+)
+
 type Position struct {
 	I, J int
 }
+
+// UnmarshalFlag parses positions given as "i,j" on the command line
+func (pos *Position) UnmarshalFlag(value string) error {
+	if _, err := fmt.Sscanf(value, "%d,%d", &pos.I, &pos.J); err != nil {
+		return fmt.Errorf("position %q must look like i,j: %w", value, err)
+	}
+	return nil
+}
+
+func (pos Position) MarshalFlag() (string, error) {
+	return fmt.Sprintf("%d,%d", pos.I, pos.J), nil
+}
@@ -0,0 +1,243 @@
+package internal
+
+import "sort"
+
+// triangle keeps its vertices counter-clockwise and the neighbour across
+// the edge opposite every vertex
+type triangle struct {
+	v     [3]int
+	n     [3]int
+	alive bool
+}
+
+// delaunay is the Bowyer-Watson triangulation. The last point is the ghost
+// vertex infinitely far away, every edge of the convex hull makes a ghost
+// triangle with it. A finite super triangle instead would keep some of the
+// thin triangles along the hull out
+type delaunay struct {
+	x, y      []float64
+	triangles []triangle
+	last      int
+}
+
+// ghost returns the place of the ghost vertex in the triangle, -1 if it has none
+func (d *delaunay) ghost(t *triangle) int {
+	for k, v := range t.v {
+		if v == len(d.x)-1 {
+			return k
+		}
+	}
+	return -1
+}
+
+func orient(ax, ay, bx, by, cx, cy float64) float64 {
+	return (bx-ax)*(cy-ay) - (by-ay)*(cx-ax)
+}
+
+// inCircle tells whether the point lies inside the circumcircle of the
+// triangle. The circle of a ghost triangle is the half-plane beyond its hull
+// edge with the open edge itself
+func (d *delaunay) inCircle(t *triangle, px, py float64) bool {
+	if k := d.ghost(t); k != -1 {
+		a, b := t.v[(k+1)%3], t.v[(k+2)%3]
+		if side := orient(d.x[a], d.y[a], d.x[b], d.y[b], px, py); side != 0 {
+			return side > 0
+		}
+		return (px-d.x[a])*(px-d.x[b])+(py-d.y[a])*(py-d.y[b]) < 0
+	}
+	var m [3][3]float64
+	for k, v := range t.v {
+		dx, dy := d.x[v]-px, d.y[v]-py
+		m[k] = [3]float64{dx, dy, dx*dx + dy*dy}
+	}
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	return det > 0
+}
+
+// outside returns the edge the point lies beyond, -1 if the triangle holds
+// it. A ghost triangle holds the points beyond its hull edge
+func (d *delaunay) outside(t *triangle, px, py float64) int {
+	if k := d.ghost(t); k != -1 {
+		a, b := t.v[(k+1)%3], t.v[(k+2)%3]
+		if orient(d.x[a], d.y[a], d.x[b], d.y[b], px, py) > 0 {
+			return -1
+		}
+		return k
+	}
+	for k := 0; k < 3; k++ {
+		a, b := t.v[(k+1)%3], t.v[(k+2)%3]
+		if orient(d.x[a], d.y[a], d.x[b], d.y[b], px, py) < 0 {
+			return k
+		}
+	}
+	return -1
+}
+
+// locate walks from the last new triangle towards the point, the points
+// come in a spatial order so the walks are short
+func (d *delaunay) locate(px, py float64) int {
+	t := d.last
+	for steps := 0; steps < len(d.triangles); steps++ {
+		k := d.outside(&d.triangles[t], px, py)
+		if k == -1 {
+			return t
+		}
+		t = d.triangles[t].n[k]
+	}
+	// The walk may circle on the degenerate triangles
+	for idx := range d.triangles {
+		if d.triangles[idx].alive && d.outside(&d.triangles[idx], px, py) == -1 {
+			return idx
+		}
+	}
+	return -1
+}
+
+func (d *delaunay) insert(p int) {
+	px, py := d.x[p], d.y[p]
+	start := d.locate(px, py)
+	if start == -1 {
+		return
+	}
+
+	// The cavity is every triangle whose circumcircle holds the point
+	bad := map[int]bool{start: true}
+	stack := []int{start}
+	for len(stack) != 0 {
+		t := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, n := range d.triangles[t].n {
+			if !bad[n] && d.inCircle(&d.triangles[n], px, py) {
+				bad[n] = true
+				stack = append(stack, n)
+			}
+		}
+	}
+
+	// Every boundary edge of the cavity makes a new triangle with the point.
+	// The new triangle (a, b, p) meets the one starting at b across the edge
+	// (b, p) and the one continuing from a across the edge (p, a)
+	type edge struct{ a, b, outer, from int }
+	var edges []edge
+	for t := range bad {
+		tri := &d.triangles[t]
+		for k := 0; k < 3; k++ {
+			if n := tri.n[k]; !bad[n] {
+				edges = append(edges, edge{tri.v[(k+1)%3], tri.v[(k+2)%3], n, t})
+			}
+		}
+		tri.alive = false
+	}
+	starting, ending := map[int]int{}, map[int]int{}
+	for _, e := range edges {
+		idx := len(d.triangles)
+		d.triangles = append(d.triangles, triangle{v: [3]int{e.a, e.b, p}, n: [3]int{-1, -1, e.outer}, alive: true})
+		starting[e.a], ending[e.b] = idx, idx
+		outer := &d.triangles[e.outer]
+		for k := range outer.n {
+			if outer.n[k] == e.from {
+				outer.n[k] = idx
+			}
+		}
+	}
+	for idx := len(d.triangles) - len(edges); idx < len(d.triangles); idx++ {
+		tri := &d.triangles[idx]
+		tri.n[0], tri.n[1] = starting[tri.v[1]], ending[tri.v[0]]
+	}
+	d.last = len(d.triangles) - 1
+}
+
+// triangulate returns the Delaunay triangles of the distinct points as the
+// triples of their indices
+func triangulate(x, y []float64) (result [][3]int) {
+	count := len(x)
+	if count < 3 {
+		return nil
+	}
+	minX, minY, maxX, maxY := x[0], y[0], x[0], y[0]
+	for idx := range x {
+		minX, maxX = minFloat(minX, x[idx]), maxFloat(maxX, x[idx])
+		minY, maxY = minFloat(minY, y[idx]), maxFloat(maxY, y[idx])
+	}
+	size := maxFloat(maxX-minX, maxY-minY)
+	if size == 0 {
+		return nil
+	}
+
+	// The rows of buckets go back and forth, so the next point is close to the last one
+	side := 1
+	for side*side < count/4 {
+		side++
+	}
+	bucket := func(idx int) (row, column int) {
+		row = int((y[idx] - minY) / size * float64(side-1))
+		column = int((x[idx] - minX) / size * float64(side-1))
+		if row%2 == 1 {
+			column = side - column
+		}
+		return
+	}
+	order := make([]int, count)
+	for idx := range order {
+		order[idx] = idx
+	}
+	sort.Slice(order, func(a, b int) bool {
+		rowA, columnA := bucket(order[a])
+		rowB, columnB := bucket(order[b])
+		if rowA != rowB {
+			return rowA < rowB
+		}
+		return columnA < columnB
+	})
+
+	// The first triangle is the first three points not on a line
+	a, b, third := order[0], order[1], -1
+	for k := 2; k < count && third == -1; k++ {
+		if orient(x[a], y[a], x[b], y[b], x[order[k]], y[order[k]]) != 0 {
+			third = k
+		}
+	}
+	if third == -1 {
+		return nil
+	}
+	c := order[third]
+	if orient(x[a], y[a], x[b], y[b], x[c], y[c]) < 0 {
+		a, b = b, a
+	}
+	g := count
+	d := &delaunay{x: append(append([]float64{}, x...), 0), y: append(append([]float64{}, y...), 0)}
+	d.triangles = []triangle{
+		{v: [3]int{a, b, c}, n: [3]int{2, 3, 1}, alive: true},
+		{v: [3]int{b, a, g}, n: [3]int{3, 2, 0}, alive: true},
+		{v: [3]int{c, b, g}, n: [3]int{1, 3, 0}, alive: true},
+		{v: [3]int{a, c, g}, n: [3]int{2, 1, 0}, alive: true},
+	}
+	for k, idx := range order {
+		if k >= 2 && k != third {
+			d.insert(idx)
+		}
+	}
+
+	for _, t := range d.triangles {
+		if t.alive && d.ghost(&t) == -1 {
+			result = append(result, t.v)
+		}
+	}
+	return
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
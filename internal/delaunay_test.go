@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// hullArea is the area of the convex hull by the monotone chain
+func hullArea(x, y []float64) (area float64) {
+	order := make([]int, len(x))
+	for idx := range order {
+		order[idx] = idx
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return x[order[a]] < x[order[b]] || x[order[a]] == x[order[b]] && y[order[a]] < y[order[b]]
+	})
+	hull := make([]int, 0, 2*len(order))
+	for pass := 0; pass < 2; pass++ {
+		start := len(hull)
+		for _, idx := range order {
+			for len(hull) >= start+2 && orient(x[hull[len(hull)-2]], y[hull[len(hull)-2]], x[hull[len(hull)-1]], y[hull[len(hull)-1]], x[idx], y[idx]) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, idx)
+		}
+		hull = hull[:len(hull)-1]
+		for a, b := 0, len(order)-1; a < b; a, b = a+1, b-1 {
+			order[a], order[b] = order[b], order[a]
+		}
+	}
+	for k := range hull {
+		a, b := hull[k], hull[(k+1)%len(hull)]
+		area += x[a]*y[b] - x[b]*y[a]
+	}
+	return area / 2
+}
+
+func TestTriangulate(t *testing.T) {
+	r := rand.New(rand.NewSource(9))
+	cases := []struct {
+		name  string
+		count int
+		point func(idx int) (x, y float64)
+	}{
+		{"triangle", 3, func(int) (float64, float64) { return 100 * r.Float64(), 50 * r.Float64() }},
+		{"random", 300, func(int) (float64, float64) { return 100 * r.Float64(), 50 * r.Float64() }},
+		// The long hull edges have the huge circles a super triangle falls into
+		{"thin", 300, func(int) (float64, float64) { return 1000 * r.Float64(), r.Float64() }},
+		{"grid", 100, func(idx int) (float64, float64) { return float64(idx % 10), float64(idx / 10) }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			x, y := make([]float64, c.count), make([]float64, c.count)
+			for idx := range x {
+				x[idx], y[idx] = c.point(idx)
+			}
+			triangles := triangulate(x, y)
+			// The last point stands for the ghost vertex
+			d := &delaunay{x: append(append([]float64{}, x...), 0), y: append(append([]float64{}, y...), 0)}
+			area := 0.
+			for _, v := range triangles {
+				a := orient(x[v[0]], y[v[0]], x[v[1]], y[v[1]], x[v[2]], y[v[2]])
+				if a <= 0 {
+					t.Fatalf("the triangle %v is not counter-clockwise", v)
+				}
+				area += a / 2
+				// No point is inside the circumcircle of a Delaunay triangle
+				for p := range x {
+					if p != v[0] && p != v[1] && p != v[2] && d.inCircle(&triangle{v: v}, x[p], y[p]) {
+						t.Fatalf("the point %d is inside the circle of %v", p, v)
+					}
+				}
+			}
+			// The triangles cover the convex hull without overlaps
+			if hull := hullArea(x, y); math.Abs(area-hull) > 1e-9*hull {
+				t.Errorf("the triangles cover %g of the hull %g", area, hull)
+			}
+		})
+	}
+}
+
+func TestTriangulateDegenerate(t *testing.T) {
+	if triangles := triangulate([]float64{0, 1, 2}, []float64{0, 1, 2}); len(triangles) != 0 {
+		t.Errorf("triangulated the points on a line into %v", triangles)
+	}
+	if triangles := triangulate([]float64{0, 1}, []float64{0, 1}); triangles != nil {
+		t.Errorf("triangulated two points into %v", triangles)
+	}
+}
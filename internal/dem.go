@@ -0,0 +1,222 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// esriMagic is the first key of the ESRI ASCII grids
+const esriMagic = "ncols"
+
+// ReadESRIASCII reads the ESRI ASCII grid. The columns become i and the rows
+// become j, so the north is at j = 0 as in the images. A grid finer than a
+// degree lying within the longitudes and the latitudes is taken for one in
+// degrees, its cell size is converted to metres as the one of the SRTM tiles
+func ReadESRIASCII(reader io.Reader) (hm *HeightMap, err error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(bufio.ScanWords)
+
+	header := map[string]float64{}
+	var first string
+	for scanner.Scan() {
+		token := scanner.Text()
+		if _, err := strconv.ParseFloat(token, 64); err == nil {
+			first = token
+			break
+		}
+		if !scanner.Scan() {
+			break
+		}
+		value, err := strconv.ParseFloat(scanner.Text(), 64)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect value of %s: %w", token, err)
+		}
+		header[strings.ToLower(token)] = value
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	for _, key := range []string{"ncols", "nrows", "cellsize"} {
+		if _, ok := header[key]; !ok {
+			return nil, fmt.Errorf("no %s in the header", key)
+		}
+	}
+
+	cols, rows, cellSize := int(header["ncols"]), int(header["nrows"]), header["cellsize"]
+	hm = EmptyHeightMap(cols, rows)
+	hm.CellSize = float32(cellSize)
+	// The header gives the lower left corner or the centre of the lower left cell
+	origin := [2]float64{header["xllcorner"], header["yllcorner"] + float64(rows)*cellSize}
+	if _, ok := header["xllcenter"]; ok {
+		origin[0] = header["xllcenter"] - cellSize/2
+	}
+	if _, ok := header["yllcenter"]; ok {
+		origin[1] = header["yllcenter"] - cellSize/2 + float64(rows)*cellSize
+	}
+	hm.Origin = &origin
+	if cellSize < 1 && origin[0] >= -180 && origin[0]+float64(cols)*cellSize <= 360 &&
+		origin[1]-float64(rows)*cellSize >= -90 && origin[1] <= 90 {
+		hm.DegreeStep, hm.CellSize = cellSize, float32(3600*cellSize*metersPerArcSecond)
+	}
+	if value, ok := header["nodata_value"]; ok {
+		noData := float32(value)
+		hm.NoData = &noData
+	}
+
+	for idx := 0; idx < rows*cols; idx++ {
+		token := first
+		if idx != 0 {
+			if !scanner.Scan() {
+				if err = scanner.Err(); err == nil {
+					err = fmt.Errorf("%d values of %d", idx, rows*cols)
+				}
+				return nil, err
+			}
+			token = scanner.Text()
+		}
+		value, err := strconv.ParseFloat(token, 32)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect value at %d: %w", idx, err)
+		}
+		hm.SetAt(idx%cols, idx/cols, float32(value))
+	}
+	return
+}
+
+// hgtNoData marks the voids of the SRTM tiles
+const hgtNoData = math.MinInt16
+
+// metersPerArcSecond is the length of an arc second of the latitude
+const metersPerArcSecond = 30.87
+
+var hgtName = regexp.MustCompile(`^([NSns])(\d{2})([EWew])(\d{3})`)
+
+// hgtCorner parses the latitude and the longitude of the south west corner from the tile name
+func hgtCorner(filePath string) (lat, lon int, err error) {
+	match := hgtName.FindStringSubmatch(filepath.Base(filePath))
+	if match == nil {
+		return 0, 0, fmt.Errorf("%q is not named as an SRTM tile", filepath.Base(filePath))
+	}
+	lat, _ = strconv.Atoi(match[2])
+	lon, _ = strconv.Atoi(match[4])
+	if strings.EqualFold(match[1], "S") {
+		lat = -lat
+	}
+	if strings.EqualFold(match[3], "W") {
+		lon = -lon
+	}
+	return
+}
+
+// ReadHGT reads the SRTM tile, both 1 and 3 arc second ones. The cell size is
+// the north-south distance between the samples in meters, the east-west one is
+// shorter by the cosine of the latitude. The origin and the DegreeStep are in
+// degrees
+func ReadHGT(filePath string) (hm *HeightMap, err error) {
+	lat, lon, err := hgtCorner(filePath)
+	if err != nil {
+		return
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return
+	}
+	size := int(math.Sqrt(float64(len(data) / 2)))
+	if size < 2 || size*size*2 != len(data) {
+		return nil, fmt.Errorf("%q is not a square tile", filePath)
+	}
+
+	hm = EmptyHeightMap(size, size)
+	step := 1 / float64(size-1)
+	hm.CellSize, hm.DegreeStep = float32(3600*step*metersPerArcSecond), step
+	// The samples lie on the edges of the tile, so the cells stick out by a half
+	hm.Origin = &[2]float64{float64(lon) - step/2, float64(lat+1) + step/2}
+	noData := float32(hgtNoData)
+	hm.NoData = &noData
+	for row := 0; row < size; row++ {
+		for col := 0; col < size; col++ {
+			offset := (row*size + col) * 2
+			hm.SetAt(col, row, float32(int16(binary.BigEndian.Uint16(data[offset:]))))
+		}
+	}
+	return
+}
+
+// MosaicHGT joins adjacent SRTM tiles of the same resolution. The gaps in the
+// bounding box of the tiles are filled with no data
+func MosaicHGT(filePaths ...string) (hm *HeightMap, err error) {
+	type corner struct{ lat, lon int }
+	tiles := map[corner]*HeightMap{}
+	order := make([]corner, 0, len(filePaths))
+	size := 0
+	var south, north, west, east int
+	for idx, filePath := range filePaths {
+		lat, lon, err := hgtCorner(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := tiles[corner{lat, lon}]; ok {
+			return nil, fmt.Errorf("the tile %q is given twice", filePath)
+		}
+		tile, err := ReadHGT(filePath)
+		if err != nil {
+			return nil, err
+		}
+		tileSize, _ := tile.Bounds()
+		if idx == 0 {
+			size, south, north, west, east = tileSize, lat, lat, lon, lon
+		} else if tileSize != size {
+			return nil, fmt.Errorf("%q has another resolution", filePath)
+		}
+		if lat < south {
+			south = lat
+		}
+		if lat > north {
+			north = lat
+		}
+		if lon < west {
+			west = lon
+		}
+		if lon > east {
+			east = lon
+		}
+		tiles[corner{lat, lon}] = tile
+		order = append(order, corner{lat, lon})
+	}
+	if len(tiles) == 0 {
+		return nil, fmt.Errorf("no tiles")
+	}
+
+	// The neighbour tiles share the edge samples
+	cols, rows := (east-west+1)*(size-1)+1, (north-south+1)*(size-1)+1
+	hm = EmptyHeightMap(cols, rows)
+	noData := float32(hgtNoData)
+	hm.NoData = &noData
+	for idx := range hm.Heights {
+		hm.Heights[idx] = noData
+	}
+	for _, c := range order {
+		tile := tiles[c]
+		iFrom, jFrom := (c.lon-west)*(size-1), (north-c.lat)*(size-1)
+		for i := 0; i < size; i++ {
+			for j := 0; j < size; j++ {
+				// A void on the shared edge must not hide the sample of the neighbour
+				if value := tile.At(i, j); value != noData {
+					hm.SetAt(iFrom+i, jFrom+j, value)
+				}
+			}
+		}
+	}
+	step := 1 / float64(size-1)
+	hm.CellSize, hm.DegreeStep = float32(3600*step*metersPerArcSecond), step
+	hm.Origin = &[2]float64{float64(west) - step/2, float64(north+1) + step/2}
+	return
+}
@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadESRIASCII(t *testing.T) {
+	cases := []struct {
+		name    string
+		text    string
+		origin  [2]float64
+		degrees bool
+		noData  bool
+		heights []float32
+		fails   bool
+	}{
+		{"corner", "ncols 3\nnrows 2\nxllcorner 100\nyllcorner 200\ncellsize 10\n1 2 3\n4 5 6\n", [2]float64{100, 220}, false, false, []float32{1, 2, 3, 4, 5, 6}, false},
+		{"centre", "NCOLS 3\nNROWS 2\nXLLCENTER 105\nYLLCENTER 205\nCELLSIZE 10\nNODATA_VALUE -9999\n1 2 3\n4 -9999 6\n", [2]float64{100, 220}, false, true, []float32{1, 2, 3, 4, -9999, 6}, false},
+		{"ragged lines", "ncols 3\nnrows 2\ncellsize 1\n1 2\n3 4 5 6\n", [2]float64{0, 2}, false, false, []float32{1, 2, 3, 4, 5, 6}, false},
+		{"degrees", "ncols 3\nnrows 2\nxllcorner 6\nyllcorner 45\ncellsize 0.5\n1 2 3\n4 5 6\n", [2]float64{6, 46}, true, false, []float32{1, 2, 3, 4, 5, 6}, false},
+		{"no cell size", "ncols 3\nnrows 2\n1 2 3\n4 5 6\n", [2]float64{}, false, false, nil, true},
+		{"short", "ncols 3\nnrows 2\ncellsize 1\n1 2 3\n4 5\n", [2]float64{}, false, false, nil, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hm, err := ReadHeightMap(strings.NewReader(c.text))
+			if c.fails {
+				if err == nil {
+					t.Error("read the incorrect grid")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if iMax, jMax := hm.Bounds(); iMax != 3 || jMax != 2 {
+				t.Fatalf("read %dx%d cells instead of 3x2", iMax, jMax)
+			}
+			if *hm.Origin != c.origin || (hm.NoData != nil) != c.noData {
+				t.Errorf("read the origin %v and no data %v", *hm.Origin, hm.NoData)
+			}
+			// The cells in degrees are measured in metres
+			if c.degrees && (hm.DegreeStep != 0.5 || hm.Spacing() != float32(1800*metersPerArcSecond)) || !c.degrees && hm.DegreeStep != 0 {
+				t.Errorf("read the cells %g degrees and %g apart", hm.DegreeStep, hm.Spacing())
+			}
+			// The rows go from the north to the south
+			for idx, height := range c.heights {
+				if hm.At(idx%3, idx/3) != height {
+					t.Errorf("(%d, %d) is %g instead of %g", idx%3, idx/3, hm.At(idx%3, idx/3), height)
+				}
+			}
+		})
+	}
+}
+
+// writeHGT stores the samples of the square tile row by row from the north west
+func writeHGT(t *testing.T, dir, name string, samples []int16) string {
+	t.Helper()
+	data := make([]byte, 2*len(samples))
+	for idx, sample := range samples {
+		binary.BigEndian.PutUint16(data[2*idx:], uint16(sample))
+	}
+	filePath := filepath.Join(dir, name)
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestReadHGT(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		name   string
+		origin [2]float64
+	}{
+		{"N45E006.hgt", [2]float64{5.75, 46.25}},
+		{"s01w002.hgt", [2]float64{-2.25, 0.25}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hm, err := ReadHGT(writeHGT(t, dir, c.name, []int16{1, 2, 3, 4, hgtNoData, 6, 7, 8, -9}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *hm.Origin != c.origin || hm.DegreeStep != 0.5 {
+				t.Errorf("the origin is %v of the step %g instead of %v", *hm.Origin, hm.DegreeStep, c.origin)
+			}
+			if hm.At(2, 0) != 3 || hm.At(0, 2) != 7 || hm.At(2, 2) != -9 || !hm.IsNoData(1, 1) {
+				t.Errorf("read %v", hm.Heights)
+			}
+		})
+	}
+	if _, err := ReadHGT(writeHGT(t, dir, "N45E007.hgt", []int16{1, 2, 3})); err == nil {
+		t.Error("read the tile which is not square")
+	}
+	if _, err := ReadHGT(writeHGT(t, dir, "tile.hgt", []int16{1, 2, 3, 4})); err == nil {
+		t.Error("read the tile without the corner in the name")
+	}
+}
+
+func TestMosaicHGT(t *testing.T) {
+	dir := t.TempDir()
+	// The void on the shared edge of the west tile is filled by the east one
+	west := writeHGT(t, dir, "N45E006.hgt", []int16{1, 2, 3, 4, 5, hgtNoData, 7, 8, 9})
+	east := writeHGT(t, dir, "N45E007.hgt", []int16{3, 12, 13, 6, 15, 16, 9, 18, 19})
+	hm, err := MosaicHGT(east, west)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iMax, jMax := hm.Bounds(); iMax != 5 || jMax != 3 {
+		t.Fatalf("joined %dx%d cells instead of 5x3", iMax, jMax)
+	}
+	want := []float32{1, 2, 3, 12, 13, 4, 5, 6, 15, 16, 7, 8, 9, 18, 19}
+	for idx, height := range want {
+		if hm.At(idx%5, idx/5) != height {
+			t.Errorf("(%d, %d) is %g instead of %g", idx%5, idx/5, hm.At(idx%5, idx/5), height)
+		}
+	}
+	if *hm.Origin != [2]float64{5.75, 46.25} {
+		t.Errorf("the origin is %v", *hm.Origin)
+	}
+	if _, err = MosaicHGT(west, west); err == nil {
+		t.Error("joined the tile with itself")
+	}
+}
+
+func TestCropInDegrees(t *testing.T) {
+	hm, err := ReadHGT(writeHGT(t, t.TempDir(), "N45E006.hgt", []int16{1, 2, 3, 4, 5, 6, 7, 8, 9}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	remap, err := CropRemap(3, 3, 1, 2, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The origin moves by the degrees, not the metres of the cells
+	cropped := remap.Heights(hm)
+	if *cropped.Origin != [2]float64{6.25, 45.25} || cropped.DegreeStep != 0.5 {
+		t.Errorf("the cropped origin is %v of the step %g", *cropped.Origin, cropped.DegreeStep)
+	}
+	// The east-west spacing shrinks with the latitude 45.5 of the middle
+	if got, want := hm.EastWestSpacing(), hm.Spacing()*0.70090926; math.Abs(float64(got-want)) > 1e-3 {
+		t.Errorf("the east-west spacing is %g instead of %g", got, want)
+	}
+}
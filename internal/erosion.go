@@ -0,0 +1,303 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Pass post-processes a generated height map in place
+type Pass interface {
+	Apply(heights *HeightMap)
+	// Validate checks the configuration before anything is changed
+	Validate() error
+}
+
+// Passes are the default configurations by the pass name
+var Passes = map[string]func() Pass{
+	"hydraulic": func() Pass { return DefaultHydraulicErosion() },
+	"thermal":   func() Pass { return DefaultThermalErosion() },
+}
+
+// ParsePass reads the pass given as "name[:Field=value,...]", the fields
+// override the default configuration
+func ParsePass(spec string) (pass Pass, err error) {
+	name, fields, _ := strings.Cut(spec, ":")
+	newPass, ok := Passes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown pass %q", name)
+	}
+	pass = newPass()
+	if fields == "" {
+		return pass, pass.Validate()
+	}
+	values := map[string]float64{}
+	for _, field := range strings.Split(fields, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("field %q of the pass %s must look like Field=value", field, name)
+		}
+		if values[key], err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("field %q of the pass %s: %w", field, name, err)
+		}
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(pass); err != nil {
+		return nil, fmt.Errorf("pass %s: %w", name, err)
+	}
+	return pass, pass.Validate()
+}
+
+// HydraulicErosion drops water particles which carve the slopes they run
+// down and leave the sediment where they slow down.
+//
+// The map is cut into tiles large enough that a droplet never leaves the
+// neighbour tiles. The tiles are processed in four phases, the tiles of a
+// phase are two tiles apart, so they run in parallel without locks and the
+// result does not depend on the scheduling
+type HydraulicErosion struct {
+	Seed int64
+	// Droplets is the number of droplets per cell
+	Droplets float64
+	// Lifetime is the number of steps a droplet makes at most
+	Lifetime    int
+	Inertia     float64
+	Capacity    float64
+	MinCapacity float64
+	Erosion     float64
+	Deposition  float64
+	Evaporation float64
+	Gravity     float64
+}
+
+func DefaultHydraulicErosion() *HydraulicErosion {
+	return &HydraulicErosion{
+		Droplets:    1,
+		Lifetime:    30,
+		Inertia:     0.05,
+		Capacity:    4,
+		MinCapacity: 0.01,
+		Erosion:     0.3,
+		Deposition:  0.3,
+		Evaporation: 0.01,
+		Gravity:     4,
+	}
+}
+
+func (erosion *HydraulicErosion) Validate() error {
+	if erosion.Lifetime <= 0 {
+		return fmt.Errorf("lifetime must be positive, got %d", erosion.Lifetime)
+	}
+	if erosion.Droplets < 0 {
+		return fmt.Errorf("droplets must not be negative, got %g", erosion.Droplets)
+	}
+	return nil
+}
+
+// gradient returns the bilinear height and its gradient at the point
+func gradient(heights *HeightMap, x, y float64) (height, gx, gy float64) {
+	i, j := int(x), int(y)
+	u, v := x-float64(i), y-float64(j)
+	h00, h01 := float64(heights.At(i, j)), float64(heights.At(i, j+1))
+	h10, h11 := float64(heights.At(i+1, j)), float64(heights.At(i+1, j+1))
+	gx = (h10-h00)*(1-v) + (h11-h01)*v
+	gy = (h01-h00)*(1-u) + (h11-h10)*u
+	height = h00*(1-u)*(1-v) + h10*u*(1-v) + h01*(1-u)*v + h11*u*v
+	return
+}
+
+// deposit spreads the amount over the four cells around the point
+func deposit(heights *HeightMap, x, y, amount float64) {
+	i, j := int(x), int(y)
+	u, v := x-float64(i), y-float64(j)
+	heights.SetAt(i, j, heights.At(i, j)+float32(amount*(1-u)*(1-v)))
+	heights.SetAt(i+1, j, heights.At(i+1, j)+float32(amount*u*(1-v)))
+	heights.SetAt(i, j+1, heights.At(i, j+1)+float32(amount*(1-u)*v))
+	heights.SetAt(i+1, j+1, heights.At(i+1, j+1)+float32(amount*u*v))
+}
+
+func (erosion *HydraulicErosion) droplet(heights *HeightMap, r *rand.Rand, x, y float64) {
+	iMax, jMax := heights.Bounds()
+	dx, dy, speed, water, sediment := 0., 0., 1., 1., 0.
+	for step := 0; step < erosion.Lifetime; step++ {
+		height, gx, gy := gradient(heights, x, y)
+		dx, dy = dx*erosion.Inertia-gx*(1-erosion.Inertia), dy*erosion.Inertia-gy*(1-erosion.Inertia)
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			// A flat spot, pick any direction
+			angle := r.Float64() * 2 * math.Pi
+			dx, dy, length = math.Cos(angle), math.Sin(angle), 1
+		}
+		dx, dy = dx/length, dy/length
+		xNew, yNew := x+dx, y+dy
+		if xNew < 0 || yNew < 0 || xNew >= float64(iMax-1) || yNew >= float64(jMax-1) {
+			return
+		}
+
+		heightNew, _, _ := gradient(heights, xNew, yNew)
+		deltaHeight := heightNew - height
+		capacity := math.Max(-deltaHeight*speed*water*erosion.Capacity, erosion.MinCapacity)
+		if sediment > capacity || deltaHeight > 0 {
+			// Fill the pit the droplet runs into or drop the surplus
+			amount := (sediment - capacity) * erosion.Deposition
+			if deltaHeight > 0 {
+				amount = math.Min(deltaHeight, sediment)
+			}
+			sediment -= amount
+			deposit(heights, x, y, amount)
+		} else {
+			amount := math.Min((capacity-sediment)*erosion.Erosion, -deltaHeight)
+			sediment += amount
+			deposit(heights, x, y, -amount)
+		}
+		speed = math.Sqrt(math.Max(0, speed*speed-deltaHeight*erosion.Gravity))
+		water *= 1 - erosion.Evaporation
+		x, y = xNew, yNew
+	}
+	// The droplet has dried up
+	deposit(heights, x, y, sediment)
+}
+
+// hydraulicRounds split the droplets so that the borders of the tiles move
+// between the rounds and leave no seams
+const hydraulicRounds = 4
+
+func (erosion *HydraulicErosion) Apply(heights *HeightMap) {
+	iMax, jMax := heights.Bounds()
+	if iMax < 2 || jMax < 2 {
+		return
+	}
+	// A droplet moves by a cell per step, so it stays within a tile from its own
+	tileSize := 2 * (erosion.Lifetime + 2)
+	for round := 0; round < hydraulicRounds; round++ {
+		shift := round * tileSize / hydraulicRounds
+		tilesI, tilesJ := (iMax+shift+tileSize-1)/tileSize, (jMax+shift+tileSize-1)/tileSize
+		for phase := 0; phase < 4; phase++ {
+			var wg sync.WaitGroup
+			for ti := phase / 2; ti < tilesI; ti += 2 {
+				for tj := phase % 2; tj < tilesJ; tj += 2 {
+					wg.Add(1)
+					go func(ti, tj int) {
+						defer wg.Done()
+						tile := int64((round*tilesI+ti)*tilesJ + tj)
+						r := rand.New(rand.NewSource(erosion.Seed ^ tile<<20))
+						iFrom, jFrom := maxInt(ti*tileSize-shift, 0), maxInt(tj*tileSize-shift, 0)
+						iTo, jTo := minInt((ti+1)*tileSize-shift, iMax-1), minInt((tj+1)*tileSize-shift, jMax-1)
+						if iTo <= iFrom || jTo <= jFrom {
+							return
+						}
+						count := int(erosion.Droplets / hydraulicRounds * float64((iTo-iFrom)*(jTo-jFrom)))
+						for idx := 0; idx < count; idx++ {
+							x := float64(iFrom) + r.Float64()*float64(iTo-iFrom)
+							y := float64(jFrom) + r.Float64()*float64(jTo-jFrom)
+							erosion.droplet(heights, r, x, y)
+						}
+					}(ti, tj)
+				}
+			}
+			wg.Wait()
+		}
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// ThermalErosion lets the material slide down the slopes steeper than the
+// talus until they settle. Every iteration reads the previous heights only,
+// so the rows are processed in parallel
+type ThermalErosion struct {
+	Iterations int
+	// Talus is the steepest stable slope as the rise per cell of the spacing
+	Talus float64
+	// Rate is the share of the excess moved per iteration
+	Rate float64
+}
+
+func DefaultThermalErosion() *ThermalErosion {
+	return &ThermalErosion{Iterations: 50, Talus: 0.8, Rate: 0.5}
+}
+
+func (erosion *ThermalErosion) Validate() error {
+	if erosion.Rate <= 0 || erosion.Rate > 1 {
+		return fmt.Errorf("rate must be between 0 and 1, got %g", erosion.Rate)
+	}
+	if erosion.Talus < 0 {
+		return fmt.Errorf("talus must not be negative, got %g", erosion.Talus)
+	}
+	return nil
+}
+
+var neighbourOffsets = [8][2]int{{-1, 0}, {-1, 1}, {0, 1}, {1, 1}, {1, 0}, {1, -1}, {0, -1}, {-1, -1}}
+
+// outflow returns how much slides from the cell to the neighbour
+func (erosion *ThermalErosion) outflow(heights *HeightMap, i, j, toI, toJ int) float32 {
+	iMax, jMax := heights.Bounds()
+	talus := float32(erosion.Talus) * heights.Spacing()
+	height := heights.At(i, j)
+	var total, steepest float32
+	for _, offset := range neighbourOffsets {
+		ni, nj := i+offset[0], j+offset[1]
+		if ni < 0 || nj < 0 || ni >= iMax || nj >= jMax || heights.IsNoData(ni, nj) {
+			continue
+		}
+		if excess := height - heights.At(ni, nj) - talus; excess > 0 {
+			total += excess
+			if excess > steepest {
+				steepest = excess
+			}
+		}
+	}
+	excess := height - heights.At(toI, toJ) - talus
+	if total == 0 || excess <= 0 {
+		return 0
+	}
+	// Moving half of the steepest excess levels the two cells
+	return float32(erosion.Rate) * steepest / 2 * excess / total
+}
+
+func (erosion *ThermalErosion) Apply(heights *HeightMap) {
+	iMax, jMax := heights.Bounds()
+	next := EmptyHeightMap(iMax, jMax)
+	next.CellSize = heights.CellSize
+	for iteration := 0; iteration < erosion.Iterations; iteration++ {
+		ParallelFor(0, iMax, 1, func(i int) {
+			for j := 0; j < jMax; j++ {
+				height := heights.At(i, j)
+				if heights.IsNoData(i, j) {
+					next.SetAt(i, j, height)
+					continue
+				}
+				for _, offset := range neighbourOffsets {
+					ni, nj := i+offset[0], j+offset[1]
+					if ni < 0 || nj < 0 || ni >= iMax || nj >= jMax || heights.IsNoData(ni, nj) {
+						continue
+					}
+					height += erosion.outflow(heights, ni, nj, i, j) - erosion.outflow(heights, i, j, ni, nj)
+				}
+				next.SetAt(i, j, height)
+			}
+		})
+		heights.Heights, next.Heights = next.Heights, heights.Heights
+	}
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"math"
+	"testing"
+)
+
+func totalHeight(hm *HeightMap) (total float64) {
+	for idx, height := range hm.Heights {
+		if !hm.IsNoData(idx/hm.Stride, idx%hm.Stride) {
+			total += float64(height)
+		}
+	}
+	return
+}
+
+func TestThermalErosionConserves(t *testing.T) {
+	noData := float32(-9999)
+	withNoData := randomHeightMap(4, 20, 16)
+	withNoData.NoData = &noData
+	withNoData.SetAt(7, 5, noData)
+	cases := []struct {
+		name    string
+		heights *HeightMap
+	}{
+		{"random", randomHeightMap(3, 20, 16)},
+		{"no data", withNoData},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.heights.CellSize = 1
+			before := totalHeight(c.heights)
+			original := append([]float32{}, c.heights.Heights...)
+			DefaultThermalErosion().Apply(c.heights)
+			if c.heights.Heights[0] == original[0] && c.heights.Heights[1] == original[1] {
+				t.Error("the steep slopes did not slide")
+			}
+			// The material only moves between the cells
+			if after := totalHeight(c.heights); math.Abs(after-before) > 1e-6*math.Abs(before) {
+				t.Errorf("the total height went from %g to %g", before, after)
+			}
+			if c.heights.NoData != nil && !c.heights.IsNoData(7, 5) {
+				t.Error("the no data cell got a height")
+			}
+		})
+	}
+}
+
+func TestHydraulicErosionIsSeeded(t *testing.T) {
+	erode := func(seed int64) *HeightMap {
+		heights := randomHeightMap(5, 40, 40)
+		heights.CellSize = 1
+		erosion := DefaultHydraulicErosion()
+		erosion.Seed = seed
+		erosion.Apply(heights)
+		return heights
+	}
+	first := erode(1)
+	sameHeights(t, erode(1), first)
+	other := erode(2)
+	for idx := range first.Heights {
+		if first.Heights[idx] != other.Heights[idx] {
+			return
+		}
+	}
+	t.Error("another seed eroded the same map")
+}
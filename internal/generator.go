@@ -0,0 +1,309 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Generator produces a height map of the size. The same configuration
+// always gives the same map
+type Generator interface {
+	Generate(iMax, jMax int) *HeightMap
+	// Validate checks the configuration before anything is generated
+	Validate() error
+}
+
+// RegionGenerator is a Generator of an unbounded world, see NoiseGenerator.GenerateRegion
+type RegionGenerator interface {
+	Generator
+	GenerateRegion(iFrom, jFrom, iMax, jMax int) *HeightMap
+}
+
+// Generators are the default configurations by the algorithm name
+var Generators = map[string]func(seed int64) Generator{
+	"perlin":         func(seed int64) Generator { return DefaultNoiseGenerator(seed) },
+	"diamond-square": func(seed int64) Generator { return DefaultDiamondSquare(seed) },
+	"warped-fbm":     func(seed int64) Generator { return DefaultWarpedFBM(seed) },
+	"worley":         func(seed int64) Generator { return DefaultWorleyRidges(seed) },
+}
+
+// NewGenerator returns the default configuration of the algorithm
+func NewGenerator(algorithm string, seed int64) (Generator, error) {
+	generator, ok := Generators[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unknown generator %q", algorithm)
+	}
+	return generator(seed), nil
+}
+
+// LoadGenerator reads the JSON configuration of the algorithm, the missing
+// fields keep the default values
+func LoadGenerator(algorithm, filePath string) (generator Generator) {
+	generator, err := NewGenerator(algorithm, 0)
+	if err != nil {
+		log.WithError(err).Panic("failed to load the generator")
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		log.WithError(err).Panic("failed to read the generator configuration")
+	}
+	if err = json.Unmarshal(data, generator); err != nil {
+		log.WithError(err).Panic("failed to parse the generator configuration")
+	}
+	return
+}
+
+// heightScale returns the height multiplier, the width of the map over 50 if it is unset
+func heightScale(scale float64, jMax int) float32 {
+	if scale == 0 {
+		return float32(jMax) / 50
+	}
+	return float32(scale)
+}
+
+// DiamondSquare is the midpoint displacement on the smallest 2^n+1 square
+// covering the map. High roughness gives cliffs
+type DiamondSquare struct {
+	Seed      int64
+	Amplitude float64
+	// Roughness multiplies the displacement on every next level
+	Roughness float64
+	Scale     float64 `json:",omitempty"`
+}
+
+func DefaultDiamondSquare(seed int64) *DiamondSquare {
+	return &DiamondSquare{Seed: seed, Amplitude: 20, Roughness: 0.55}
+}
+
+func (generator *DiamondSquare) Validate() error {
+	if generator.Roughness <= 0 || generator.Roughness >= 1 {
+		return fmt.Errorf("roughness must be between 0 and 1, got %g", generator.Roughness)
+	}
+	return nil
+}
+
+func (generator *DiamondSquare) Generate(iMax, jMax int) (heights *HeightMap) {
+	size := 2
+	for size+1 < iMax || size+1 < jMax {
+		size *= 2
+	}
+	n := size + 1
+	r := rand.New(rand.NewSource(generator.Seed))
+	grid := make([]float64, n*n)
+	displace := func(amplitude float64) float64 { return (2*r.Float64() - 1) * amplitude }
+	for _, idx := range []int{0, size, size * n, size*n + size} {
+		grid[idx] = displace(1)
+	}
+
+	amplitude := generator.Roughness
+	for step := size; step > 1; step /= 2 {
+		half := step / 2
+		// Diamond step: the centre of every square
+		for x := half; x < n; x += step {
+			for y := half; y < n; y += step {
+				sum := grid[(x-half)*n+y-half] + grid[(x-half)*n+y+half] +
+					grid[(x+half)*n+y-half] + grid[(x+half)*n+y+half]
+				grid[x*n+y] = sum/4 + displace(amplitude)
+			}
+		}
+		// Square step: the middle of every edge, the map does not wrap
+		for x := 0; x < n; x += half {
+			for y := (x/half + 1) % 2 * half; y < n; y += step {
+				sum, count := 0., 0
+				for _, d := range [][2]int{{-half, 0}, {half, 0}, {0, -half}, {0, half}} {
+					if xn, yn := x+d[0], y+d[1]; xn >= 0 && yn >= 0 && xn < n && yn < n {
+						sum, count = sum+grid[xn*n+yn], count+1
+					}
+				}
+				grid[x*n+y] = sum/float64(count) + displace(amplitude)
+			}
+		}
+		amplitude *= generator.Roughness
+	}
+
+	heights = EmptyHeightMap(iMax, jMax)
+	scale := heightScale(generator.Scale, jMax)
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			heights.SetAt(i, j, float32(grid[i*n+j]*generator.Amplitude)*scale)
+		}
+	}
+	return
+}
+
+// WarpedFBM sums the octaves of the simplex noise and bends the coordinates
+// by two more such sums, which gives twisted valleys and overhang-like cliffs
+type WarpedFBM struct {
+	Seed      int64
+	Amplitude float64
+	// Frequency of the first octave per unit of the noise space
+	Frequency  float64
+	Octaves    int
+	Lacunarity float64
+	Gain       float64
+	// WarpStrength is the shift of the coordinates in units of the noise space, no warping if zero
+	WarpStrength float64
+	Scale        float64 `json:",omitempty"`
+	// CellsPerUnit is the number of world cells per unit of the noise space, 1000 if unset
+	CellsPerUnit float64 `json:",omitempty"`
+}
+
+func DefaultWarpedFBM(seed int64) *WarpedFBM {
+	return &WarpedFBM{Seed: seed, Amplitude: 20, Frequency: 1, Octaves: 5, Lacunarity: 2, Gain: 0.5, WarpStrength: 0.5}
+}
+
+func (generator *WarpedFBM) Validate() error {
+	if generator.Octaves <= 0 {
+		return fmt.Errorf("octaves must be positive, got %d", generator.Octaves)
+	}
+	return nil
+}
+
+func (generator *WarpedFBM) height() func(x, y float64) float32 {
+	noise := newSimplex(generator.Seed)
+	fbm := func(x, y float64) (sum float64) {
+		frequency, amplitude := generator.Frequency, 1.
+		for octave := 0; octave < generator.Octaves; octave++ {
+			sum += amplitude * noise.noise2D(x*frequency, y*frequency)
+			frequency, amplitude = frequency*generator.Lacunarity, amplitude*generator.Gain
+		}
+		return
+	}
+	return func(x, y float64) float32 {
+		if generator.WarpStrength != 0 {
+			// The offsets decorrelate the two warping sums
+			qx, qy := fbm(x, y), fbm(x+5.2, y+1.3)
+			x, y = x+generator.WarpStrength*qx, y+generator.WarpStrength*qy
+		}
+		return float32(generator.Amplitude * fbm(x, y))
+	}
+}
+
+func (generator *WarpedFBM) Generate(iMax, jMax int) (heights *HeightMap) {
+	return generateUnit(generator.height(), generator.Scale, iMax, jMax)
+}
+
+func (generator *WarpedFBM) GenerateRegion(iFrom, jFrom, iMax, jMax int) (heights *HeightMap) {
+	return generateRegion(generator.height(), generator.Scale, generator.CellsPerUnit, iFrom, jFrom, iMax, jMax)
+}
+
+// WorleyRidges takes the distance to the closest of the scattered feature
+// points, so the borders of the Voronoi cells become sharp ridges around the
+// basins. Terraces cut the slopes into plateaus and cliffs
+type WorleyRidges struct {
+	Seed      int64
+	Amplitude float64
+	// Frequency is the number of feature points per unit of the noise space along an axis
+	Frequency float64
+	Octaves   int
+	Gain      float64
+	// Terraces is the number of height levels, the slopes are smooth if zero
+	Terraces     int     `json:",omitempty"`
+	Scale        float64 `json:",omitempty"`
+	CellsPerUnit float64 `json:",omitempty"`
+}
+
+func DefaultWorleyRidges(seed int64) *WorleyRidges {
+	return &WorleyRidges{Seed: seed, Amplitude: 30, Frequency: 6, Octaves: 3, Gain: 0.35}
+}
+
+func (generator *WorleyRidges) Validate() error {
+	if generator.Octaves <= 0 {
+		return fmt.Errorf("octaves must be positive, got %d", generator.Octaves)
+	}
+	if generator.Frequency <= 0 {
+		return fmt.Errorf("frequency must be positive, got %g", generator.Frequency)
+	}
+	return nil
+}
+
+// worley returns the distance to the closest feature point, one point is
+// scattered in every unit cell
+func worley(seed int64, x, y float64) float64 {
+	cx, cy := math.Floor(x), math.Floor(y)
+	closest := math.Inf(1)
+	for dx := -1.; dx <= 1; dx++ {
+		for dy := -1.; dy <= 1; dy++ {
+			hash := splitMix(uint64(seed) ^ uint64(int64(cx+dx))*0x9e3779b97f4a7c15 ^ uint64(int64(cy+dy))*0xc2b2ae3d27d4eb4f)
+			px := cx + dx + float64(hash>>40)/(1<<24)
+			py := cy + dy + float64(hash&(1<<24-1))/(1<<24)
+			if distance := math.Hypot(x-px, y-py); distance < closest {
+				closest = distance
+			}
+		}
+	}
+	return closest
+}
+
+func splitMix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+func (generator *WorleyRidges) height() func(x, y float64) float32 {
+	return func(x, y float64) float32 {
+		sum, frequency, amplitude := 0., generator.Frequency, 1.
+		for octave := 0; octave < generator.Octaves; octave++ {
+			sum += amplitude * worley(generator.Seed+int64(octave), x*frequency, y*frequency)
+			frequency, amplitude = frequency*2, amplitude*generator.Gain
+		}
+		if generator.Terraces > 0 {
+			sum = math.Floor(sum*float64(generator.Terraces)) / float64(generator.Terraces)
+		}
+		return float32(generator.Amplitude * sum)
+	}
+}
+
+func (generator *WorleyRidges) Generate(iMax, jMax int) (heights *HeightMap) {
+	return generateUnit(generator.height(), generator.Scale, iMax, jMax)
+}
+
+func (generator *WorleyRidges) GenerateRegion(iFrom, jFrom, iMax, jMax int) (heights *HeightMap) {
+	return generateRegion(generator.height(), generator.Scale, generator.CellsPerUnit, iFrom, jFrom, iMax, jMax)
+}
+
+// generateUnit fits the longer side of the map into the unit of the noise space
+func generateUnit(height func(x, y float64) float32, scale float64, iMax, jMax int) (heights *HeightMap) {
+	heights = EmptyHeightMap(iMax, jMax)
+	side := float64(iMax)
+	if jMax > iMax {
+		side = float64(jMax)
+	}
+	fillHeights(heights, height, heightScale(scale, jMax), func(i, j int) (x, y float64) {
+		return float64(i) / side, float64(j) / side
+	})
+	return
+}
+
+func generateRegion(height func(x, y float64) float32, scale, cellsPerUnit float64, iFrom, jFrom, iMax, jMax int) (heights *HeightMap) {
+	if cellsPerUnit <= 0 {
+		cellsPerUnit = 1000
+	}
+	if scale == 0 {
+		scale = cellsPerUnit / 50
+	}
+	heights = EmptyHeightMap(iMax, jMax)
+	// The world cells are the units, the northing falls as j grows
+	heights.Origin = &[2]float64{float64(iFrom), -float64(jFrom)}
+	fillHeights(heights, height, float32(scale), func(i, j int) (x, y float64) {
+		return float64(iFrom+i) / cellsPerUnit, float64(jFrom+j) / cellsPerUnit
+	})
+	return
+}
+
+// fillHeights samples the height at the point of the noise space every cell is mapped onto
+func fillHeights(heights *HeightMap, height func(x, y float64) float32, scale float32, point func(i, j int) (x, y float64)) {
+	iMax, jMax := heights.Bounds()
+	for i := 0; i < iMax; i++ {
+		ParallelFor(0, jMax, 1, func(j int) {
+			heights.Heights[i*jMax+j] = height(point(i, j)) * scale
+		})
+	}
+}
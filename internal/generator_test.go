@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestGeneratorsAreSeeded(t *testing.T) {
+	names := make([]string, 0, len(Generators))
+	for name := range Generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			first := Generators[name](7).Generate(17, 13)
+			sameHeights(t, Generators[name](7).Generate(17, 13), first)
+			other := Generators[name](8).Generate(17, 13)
+			for idx := range first.Heights {
+				if first.Heights[idx] != other.Heights[idx] {
+					return
+				}
+			}
+			t.Error("another seed gave the same map")
+		})
+	}
+}
+
+func TestGenerateChunkSeams(t *testing.T) {
+	generator := DefaultNoiseGenerator(3)
+	world := generator.GenerateRegion(0, 0, 32, 16)
+	for _, chunk := range [][2]int{{0, 0}, {1, 0}} {
+		heights := generator.GenerateChunk(chunk[0], chunk[1], 16)
+		if heights.Origin == nil || *heights.Origin != [2]float64{float64(16 * chunk[0]), 0} {
+			t.Errorf("the chunk %v has the origin %v", chunk, heights.Origin)
+		}
+		remap, err := CropRemap(32, 16, 16*chunk[0], 0, 16, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sameHeights(t, heights, remap.Heights(world))
+	}
+}
+
+func TestGenerateRegionSeams(t *testing.T) {
+	generators := map[string]RegionGenerator{
+		"warped-fbm": DefaultWarpedFBM(3),
+		"worley":     DefaultWorleyRidges(3),
+	}
+	for name, generator := range generators {
+		t.Run(name, func(t *testing.T) {
+			world := generator.GenerateRegion(-5, 2, 20, 9)
+			// The window starting in the middle of the world is its crop
+			remap, err := CropRemap(20, 9, 7, 3, 10, 6)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sameHeights(t, generator.GenerateRegion(2, 5, 10, 6), remap.Heights(world))
+		})
+	}
+}
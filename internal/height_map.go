@@ -2,11 +2,12 @@ package internal
 
 import (
 	"encoding/json"
-	"github.com/aquilax/go-perlin"
 	log "github.com/sirupsen/logrus"
 	"io"
-	"io/ioutil"
+	"math"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -14,6 +15,18 @@ import (
 type HeightMap struct {
 	Heights []float32
 	Stride  int
+	// CellSize is the distance between neighbour cells in height units, 1 if unset
+	CellSize float32 `json:",omitempty"`
+	// Origin is the position of the outer corner of the cell (0, 0) in the
+	// units of the source, i grows to the east and j grows to the south so
+	// the northing falls with j
+	Origin *[2]float64 `json:",omitempty"`
+	// DegreeStep is the distance between neighbour cells in degrees when the
+	// Origin is the longitude and the latitude, 0 if the Origin is in the
+	// units of the CellSize. The CellSize is then the north-south distance
+	DegreeStep float64 `json:",omitempty"`
+	// NoData is the value of the cells without a measurement, nil if all the cells are measured
+	NoData *float32 `json:",omitempty"`
 }
 
 func EmptyHeightMap(iMax, jMax int) (heights *HeightMap) {
@@ -23,34 +36,28 @@ func EmptyHeightMap(iMax, jMax int) (heights *HeightMap) {
 	return
 }
 
+// GenerateHeightMap generates a map with the default noise and a random seed,
+// use NoiseGenerator to get a reproducible one
 func GenerateHeightMap(iMax, jMax int) (heights *HeightMap) {
-	heights = EmptyHeightMap(iMax, jMax)
-	p := perlin.NewPerlin(4., 2., 7, time.Now().UnixMilli())
-	noise := func(x, y float64) float32 {
-		return float32(p.Noise2D(x, y))
-	}
-	for i := 0; i < iMax; i++ {
-		ParallelFor(0, jMax, 1, func(j int) {
-			ni, nj := float64(i)/float64(iMax)-0.5, float64(j)/float64(jMax)-0.5
-			heights.Heights[i*jMax+j] = 15*noise(ni, nj) +
-				7*noise(2*ni, 2*nj) +
-				3*noise(4*ni, 2*nj) +
-				3*noise(2*ni, 4*nj) +
-				noise(7*ni, 7*nj) +
-				3*noise(10*ni, 8*nj)
-			heights.Heights[i*jMax+j] *= float32(jMax) / 50
-		})
-	}
-	return
+	return DefaultNoiseGenerator(time.Now().UnixMilli()).Generate(iMax, jMax)
 }
 
+// LoadHeightMap reads the height map in any of the supported formats
 func LoadHeightMap(filePath string) (heights *HeightMap) {
-	file, err := ioutil.ReadFile(filePath)
+	// The SRTM tiles have no header, only the name tells what they are
+	if strings.EqualFold(filepath.Ext(filePath), ".hgt") {
+		heights, err := ReadHGT(filePath)
+		if err != nil {
+			log.WithError(err).Panic("failed to load height map from file")
+		}
+		return heights
+	}
+	file, err := os.Open(filePath)
 	if err != nil {
 		log.WithError(err).Panic("failed to load height map from file")
 	}
-	heights = new(HeightMap)
-	if err = json.Unmarshal([]byte(file), heights); err != nil {
+	defer file.Close()
+	if heights, err = ReadHeightMap(file); err != nil {
 		log.WithError(err).Panic("failed to load height map from file")
 	}
 	return
@@ -61,6 +68,67 @@ func (hm *HeightMap) Bounds() (iMax, jMax int) {
 	return
 }
 
+// Spacing returns the distance between neighbour cells in height units
+func (hm *HeightMap) Spacing() float32 {
+	if hm.CellSize <= 0 {
+		return 1
+	}
+	return hm.CellSize
+}
+
+// originStep returns the distance between neighbour cells in the units of the Origin
+func (hm *HeightMap) originStep() float64 {
+	if hm.DegreeStep != 0 {
+		return hm.DegreeStep
+	}
+	return float64(hm.Spacing())
+}
+
+// Coordinates returns the point given in cells, the centre of the cell (i, j)
+// is at the integer indexes, in the units of the Origin: the longitude and the
+// latitude if the map is in degrees. The map without an Origin keeps the cells
+func (hm *HeightMap) Coordinates(i, j float64) (x, y float64) {
+	if hm.Origin == nil {
+		return i, j
+	}
+	step := hm.originStep()
+	return hm.Origin[0] + (i+0.5)*step, hm.Origin[1] - (j+0.5)*step
+}
+
+// EastWestSpacing returns the distance between neighbour cells along i. It is
+// shorter than the Spacing on the maps in degrees by the cosine of the
+// latitude of their middle
+func (hm *HeightMap) EastWestSpacing() float32 {
+	if hm.DegreeStep == 0 || hm.Origin == nil {
+		return hm.Spacing()
+	}
+	_, jMax := hm.Bounds()
+	latitude := hm.Origin[1] - hm.DegreeStep*float64(jMax)/2
+	return hm.Spacing() * float32(math.Cos(latitude*math.Pi/180))
+}
+
+// IsNoData tells whether the cell has no measurement
+func (hm *HeightMap) IsNoData(i, j int) bool {
+	return hm.NoData != nil && hm.At(i, j) == *hm.NoData
+}
+
+// heightRange returns the lowest and the highest measured heights
+func (hm *HeightMap) heightRange() (min, max float32, ok bool) {
+	for _, height := range hm.Heights {
+		if hm.NoData != nil && height == *hm.NoData {
+			continue
+		}
+		if !ok || height < min {
+			min = height
+		}
+		if !ok || height > max {
+			max = height
+		}
+		ok = true
+	}
+	return
+}
+
 func (hm *HeightMap) At(i, j int) float32 {
 	return hm.Heights[i*hm.Stride+j]
 }
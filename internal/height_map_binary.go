@@ -0,0 +1,261 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// The binary height map starts with the header below followed by the
+// heights row by row. All the numbers are little endian.
+//
+//	magic       [4]byte "THMB"
+//	version     uint16
+//	data type   uint8
+//	compression uint8
+//	rows, cols  uint32
+//	cell size   float32
+//	scale       float32 only meaningful for the quantised data
+//	offset      float32 only meaningful for the quantised data
+//
+// Since version 2 the header goes on with the georeference
+//
+//	has origin  uint8
+//	has no data uint8
+//	padding     [2]byte
+//	origin      [2]float64
+//	no data     float32 stored as -32768 in the quantised data
+//
+// Since version 3 the georeference goes on with
+//
+//	degree step float64 0 if the origin is not in degrees
+var binaryMagic = [4]byte{'T', 'H', 'M', 'B'}
+
+const binaryVersion uint16 = 3
+
+// quantisedNoData is the int16 level left out of the quantisation range
+const quantisedNoData = math.MinInt16
+
+type DataType uint8
+
+const (
+	Float32Data DataType = iota
+	// Int16Data quantises the heights into 65535 levels between the lowest and the highest cell
+	Int16Data
+)
+
+type Compression uint8
+
+const (
+	NoCompression Compression = iota
+	GzipCompression
+)
+
+type BinaryOptions struct {
+	DataType    DataType
+	Compression Compression
+}
+
+type binaryHeader struct {
+	Magic       [4]byte
+	Version     uint16
+	DataType    DataType
+	Compression Compression
+	Rows, Cols  uint32
+	CellSize    float32
+	Scale       float32
+	Offset      float32
+}
+
+type binaryGeoreference struct {
+	HasOrigin uint8
+	HasNoData uint8
+	_         [2]byte
+	Origin    [2]float64
+	NoData    float32
+}
+
+type binaryGeographic struct {
+	DegreeStep float64
+}
+
+// quantisation returns the scale and the offset mapping int16 values onto the heights range
+func (hm *HeightMap) quantisation() (scale, offset float32) {
+	min, max, ok := hm.heightRange()
+	if !ok {
+		return 1, 0
+	}
+	scale = (max - min) / (math.MaxInt16 - math.MinInt16 - 1)
+	if scale == 0 {
+		scale = 1
+	}
+	return scale, (max + min) / 2
+}
+
+// FlushBinary writes the height map in the binary format
+func (hm *HeightMap) FlushBinary(writer io.Writer, options BinaryOptions) (err error) {
+	iMax, jMax := hm.Bounds()
+	header := binaryHeader{
+		Magic:       binaryMagic,
+		Version:     binaryVersion,
+		DataType:    options.DataType,
+		Compression: options.Compression,
+		Rows:        uint32(iMax),
+		Cols:        uint32(jMax),
+		CellSize:    hm.CellSize,
+		Scale:       1,
+	}
+	if options.DataType == Int16Data {
+		header.Scale, header.Offset = hm.quantisation()
+	}
+	if err = binary.Write(writer, binary.LittleEndian, &header); err != nil {
+		return
+	}
+	georeference := binaryGeoreference{}
+	if hm.Origin != nil {
+		georeference.HasOrigin, georeference.Origin = 1, *hm.Origin
+	}
+	if hm.NoData != nil {
+		georeference.HasNoData, georeference.NoData = 1, *hm.NoData
+	}
+	if err = binary.Write(writer, binary.LittleEndian, &georeference); err != nil {
+		return
+	}
+	if err = binary.Write(writer, binary.LittleEndian, &binaryGeographic{hm.DegreeStep}); err != nil {
+		return
+	}
+
+	var body io.Writer = writer
+	switch options.Compression {
+	case NoCompression:
+	case GzipCompression:
+		compressor := gzip.NewWriter(writer)
+		defer func() {
+			if closeErr := compressor.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		body = compressor
+	default:
+		return fmt.Errorf("unknown compression %d", options.Compression)
+	}
+
+	buffered := bufio.NewWriter(body)
+	switch options.DataType {
+	case Float32Data:
+		err = binary.Write(buffered, binary.LittleEndian, hm.Heights)
+	case Int16Data:
+		values := make([]int16, len(hm.Heights))
+		for idx, height := range hm.Heights {
+			if hm.NoData != nil && height == *hm.NoData {
+				values[idx] = quantisedNoData
+				continue
+			}
+			values[idx] = int16(math.Round(float64((height - header.Offset) / header.Scale)))
+		}
+		err = binary.Write(buffered, binary.LittleEndian, values)
+	default:
+		return fmt.Errorf("unknown data type %d", options.DataType)
+	}
+	if err != nil {
+		return
+	}
+	return buffered.Flush()
+}
+
+func readBinaryHeightMap(reader io.Reader) (hm *HeightMap, err error) {
+	header := binaryHeader{}
+	if err = binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("failed to read the header: %w", err)
+	}
+	georeference, geographic := binaryGeoreference{}, binaryGeographic{}
+	switch header.Version {
+	case 1:
+	case 2, 3:
+		if err = binary.Read(reader, binary.LittleEndian, &georeference); err != nil {
+			return nil, fmt.Errorf("failed to read the header: %w", err)
+		}
+		if header.Version == 3 {
+			if err = binary.Read(reader, binary.LittleEndian, &geographic); err != nil {
+				return nil, fmt.Errorf("failed to read the header: %w", err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported version %d", header.Version)
+	}
+
+	switch header.Compression {
+	case NoCompression:
+	case GzipCompression:
+		decompressor, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer decompressor.Close()
+		reader = decompressor
+	default:
+		return nil, fmt.Errorf("unknown compression %d", header.Compression)
+	}
+	reader = bufio.NewReader(reader)
+
+	hm = EmptyHeightMap(int(header.Rows), int(header.Cols))
+	hm.CellSize = header.CellSize
+	if georeference.HasOrigin != 0 {
+		origin := georeference.Origin
+		hm.Origin = &origin
+	}
+	hm.DegreeStep = geographic.DegreeStep
+	if georeference.HasNoData != 0 {
+		noData := georeference.NoData
+		hm.NoData = &noData
+	}
+	switch header.DataType {
+	case Float32Data:
+		err = binary.Read(reader, binary.LittleEndian, hm.Heights)
+	case Int16Data:
+		values := make([]int16, len(hm.Heights))
+		if err = binary.Read(reader, binary.LittleEndian, values); err == nil {
+			for idx, value := range values {
+				if value == quantisedNoData && hm.NoData != nil {
+					hm.Heights[idx] = *hm.NoData
+					continue
+				}
+				hm.Heights[idx] = header.Offset + float32(value)*header.Scale
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown data type %d", header.DataType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the heights: %w", err)
+	}
+	return
+}
+
+// ReadHeightMap reads the JSON, the binary, the PNG or the ESRI ASCII height
+// map detecting the format by the magic number. The PNG gray levels are used
+// as they are
+func ReadHeightMap(reader io.Reader) (hm *HeightMap, err error) {
+	buffered := bufio.NewReader(reader)
+	if magic, err := buffered.Peek(len(esriMagic)); err == nil && strings.EqualFold(string(magic), esriMagic) {
+		return ReadESRIASCII(buffered)
+	}
+	magic, err := buffered.Peek(len(binaryMagic))
+	if err == nil && bytes.Equal(magic, binaryMagic[:]) {
+		return readBinaryHeightMap(buffered)
+	}
+	if err == nil && bytes.Equal(magic, pngMagic) {
+		return ReadPNGHeightMap(buffered, DefaultPNGOptions)
+	}
+	hm = new(HeightMap)
+	if err = json.NewDecoder(buffered).Decode(hm); err != nil {
+		return nil, err
+	}
+	return
+}
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	noData := float32(-9999)
+	cases := []struct {
+		name    string
+		options BinaryOptions
+	}{
+		{"float32", BinaryOptions{}},
+		{"float32 gzip", BinaryOptions{Compression: GzipCompression}},
+		{"int16", BinaryOptions{DataType: Int16Data}},
+		{"int16 gzip", BinaryOptions{DataType: Int16Data, Compression: GzipCompression}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hm := rampHeightMap(6, 4, -35.5, 812.25)
+			hm.CellSize, hm.Origin, hm.DegreeStep, hm.NoData = 30, &[2]float64{6.5, 45.25}, 1.0/3600, &noData
+			hm.SetAt(2, 3, noData)
+			var buffer bytes.Buffer
+			if err := hm.FlushBinary(&buffer, c.options); err != nil {
+				t.Fatal(err)
+			}
+			read, err := ReadHeightMap(&buffer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if read.CellSize != hm.CellSize || read.Origin == nil || *read.Origin != *hm.Origin || read.DegreeStep != hm.DegreeStep || read.NoData == nil || *read.NoData != noData {
+				t.Errorf("read the cell size %g, the origin %v of the step %g and no data %v", read.CellSize, read.Origin, read.DegreeStep, read.NoData)
+			}
+			epsilon := 0.
+			if c.options.DataType == Int16Data {
+				// A half of a level
+				scale, _ := hm.quantisation()
+				epsilon = float64(scale) / 2 * 1.001
+			}
+			iMax, jMax := hm.Bounds()
+			if iRead, jRead := read.Bounds(); iRead != iMax || jRead != jMax {
+				t.Fatalf("read %dx%d cells instead of %dx%d", iRead, jRead, iMax, jMax)
+			}
+			for i := 0; i < iMax; i++ {
+				for j := 0; j < jMax; j++ {
+					if hm.IsNoData(i, j) != read.IsNoData(i, j) || math.Abs(float64(read.At(i, j)-hm.At(i, j))) > epsilon {
+						t.Fatalf("(%d, %d) is %g instead of %g", i, j, read.At(i, j), hm.At(i, j))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBinaryVersion1(t *testing.T) {
+	// The first version has no georeference after the header
+	var buffer bytes.Buffer
+	header := binaryHeader{Magic: binaryMagic, Version: 1, Rows: 2, Cols: 3, CellSize: 5, Scale: 1}
+	heights := []float32{1, 2, 3, 4, 5, 6}
+	if err := binary.Write(&buffer, binary.LittleEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+	if err := binary.Write(&buffer, binary.LittleEndian, heights); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadHeightMap(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iMax, jMax := read.Bounds(); iMax != 2 || jMax != 3 || read.CellSize != 5 || read.Origin != nil || read.NoData != nil {
+		t.Fatalf("read %dx%d cells of %g with the origin %v and no data %v", iMax, jMax, read.CellSize, read.Origin, read.NoData)
+	}
+	if read.At(1, 0) != 4 || read.At(0, 2) != 3 {
+		t.Errorf("read %v instead of %v", read.Heights, heights)
+	}
+}
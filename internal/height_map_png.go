@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var pngMagic = []byte("\x89PNG")
+
+// PNGOptions maps the 16-bit gray levels onto the heights: height = Offset + Scale*level
+type PNGOptions struct {
+	// Scale is the height of a single gray level, the range of the map is
+	// fitted into the levels if zero when exporting
+	Scale  float32
+	Offset float32
+	// NoData is the height of the black level, the level is then left out of
+	// the measured heights. It is set when exporting a map with no data
+	NoData *float32
+}
+
+// Fields returns the options as the flags of the commands, so the exported
+// map can be read back
+func (options PNGOptions) Fields() log.Fields {
+	fields := log.Fields{"png-scale": options.Scale, "png-offset": options.Offset}
+	if options.NoData != nil {
+		fields["png-no-data"] = *options.NoData
+	}
+	return fields
+}
+
+// DefaultPNGOptions keeps the gray levels as they are
+var DefaultPNGOptions = PNGOptions{Scale: 1}
+
+// ReadPNGHeightMap reads a grayscale image, the pixel (x, y) is the cell (i, j)
+// the same way as in the textures. Colour images are converted to gray
+func ReadPNGHeightMap(reader io.Reader, options PNGOptions) (hm *HeightMap, err error) {
+	img, err := png.Decode(reader)
+	if err != nil {
+		return
+	}
+	bounds := img.Bounds()
+	iMax, jMax := bounds.Dx(), bounds.Dy()
+	hm = EmptyHeightMap(iMax, jMax)
+	if options.NoData != nil {
+		noData := *options.NoData
+		hm.NoData = &noData
+	}
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			level := color.Gray16Model.Convert(img.At(bounds.Min.X+i, bounds.Min.Y+j)).(color.Gray16).Y
+			if level == 0 && hm.NoData != nil {
+				hm.SetAt(i, j, *hm.NoData)
+				continue
+			}
+			hm.SetAt(i, j, options.Offset+options.Scale*float32(level))
+		}
+	}
+	return
+}
+
+// LoadPNGHeightMap reads the grayscale image from the file
+func LoadPNGHeightMap(filePath string, options PNGOptions) (hm *HeightMap) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.WithError(err).Panic("failed to load height map from file")
+	}
+	defer file.Close()
+	if hm, err = ReadPNGHeightMap(file, options); err != nil {
+		log.WithError(err).Panic("failed to load height map from file")
+	}
+	return
+}
+
+// FitPNGOptions returns the options which spread the heights over all the
+// gray levels, but the black one if the map has no data
+func (hm *HeightMap) FitPNGOptions() (options PNGOptions) {
+	min, max, ok := hm.heightRange()
+	if !ok {
+		return DefaultPNGOptions
+	}
+	lowest := float32(0)
+	if hm.NoData != nil {
+		lowest = 1
+	}
+	options.Scale = (max - min) / (math.MaxUint16 - lowest)
+	if options.Scale == 0 {
+		options.Scale = 1
+	}
+	options.Offset = min - lowest*options.Scale
+	return
+}
+
+// FlushPNG writes the height map as a 16-bit grayscale image. The heights out
+// of the range of the levels are clamped. The cells without data are black
+// and the measured ones are kept off the black level. It returns the options
+// the image must be read back with, the fitted ones if the scale is unset
+func (hm *HeightMap) FlushPNG(writer io.Writer, options PNGOptions) (used PNGOptions, err error) {
+	if options.Scale == 0 {
+		options = hm.FitPNGOptions()
+	}
+	options.NoData = hm.NoData
+	lowest := 0.
+	if hm.NoData != nil {
+		lowest = 1
+	}
+	iMax, jMax := hm.Bounds()
+	img := image.NewGray16(image.Rect(0, 0, iMax, jMax))
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			level := math.Round(float64((hm.At(i, j) - options.Offset) / options.Scale))
+			level = math.Max(lowest, math.Min(math.MaxUint16, level))
+			if hm.IsNoData(i, j) {
+				level = 0
+			}
+			img.SetGray16(i, j, color.Gray16{Y: uint16(level)})
+		}
+	}
+	return options, png.Encode(writer, img)
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// rampHeightMap has every height distinct so a swapped axis shows up
+func rampHeightMap(iMax, jMax int, low, high float32) *HeightMap {
+	hm := EmptyHeightMap(iMax, jMax)
+	step := (high - low) / float32(iMax*jMax-1)
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			hm.SetAt(i, j, low+step*float32(i*jMax+j))
+		}
+	}
+	return hm
+}
+
+func TestPNGRoundTrip(t *testing.T) {
+	noData := float32(-9999)
+	withNoData := rampHeightMap(6, 4, 10, 33)
+	withNoData.NoData = &noData
+	withNoData.SetAt(3, 2, noData)
+	cases := []struct {
+		name    string
+		heights *HeightMap
+		options PNGOptions
+	}{
+		{"fitted", rampHeightMap(7, 5, -120.5, 3400), PNGOptions{}},
+		{"fitted flat", rampHeightMap(1, 1, 42, 42), PNGOptions{}},
+		{"given", rampHeightMap(5, 7, 0, 600), PNGOptions{Scale: 0.01, Offset: -10}},
+		// The lowest measured cell stays off the black level of no data
+		{"no data", withNoData, PNGOptions{}},
+		{"given no data", withNoData, PNGOptions{Scale: 0.5, Offset: 9}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			used, err := c.heights.FlushPNG(&buffer, c.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.options.Scale != 0 && (used.Scale != c.options.Scale || used.Offset != c.options.Offset) {
+				t.Errorf("used %+v instead of the given %+v", used, c.options)
+			}
+			read, err := ReadPNGHeightMap(&buffer, used)
+			if err != nil {
+				t.Fatal(err)
+			}
+			iMax, jMax := c.heights.Bounds()
+			if iRead, jRead := read.Bounds(); iRead != iMax || jRead != jMax {
+				t.Fatalf("read %dx%d cells instead of %dx%d", iRead, jRead, iMax, jMax)
+			}
+			// A height is within a half of a gray level
+			epsilon := float64(used.Scale)/2 + 1e-3
+			for i := 0; i < iMax; i++ {
+				for j := 0; j < jMax; j++ {
+					if read.IsNoData(i, j) != c.heights.IsNoData(i, j) || math.Abs(float64(read.At(i, j)-c.heights.At(i, j))) > epsilon {
+						t.Fatalf("(%d, %d) is %g instead of %g", i, j, read.At(i, j), c.heights.At(i, j))
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package internal
+
+import (
+	"container/heap"
+	"image"
+	"image/color"
+	"math"
+)
+
+// WaterKind tells what the water mask keeps in a cell
+type WaterKind uint8
+
+const (
+	DryCell WaterKind = iota
+	RiverCell
+	LakeCell
+)
+
+// HydrologyOptions are the thresholds the rivers and the lakes are extracted with
+type HydrologyOptions struct {
+	// RiverThreshold is the number of upstream cells, the own cell included,
+	// a cell must drain to hold a river
+	RiverThreshold float32
+	// LakeDepth is the depth of water the depression must hold
+	LakeDepth float32
+	// MinLakeArea is the number of cells a lake must cover, smaller ones stay dry
+	MinLakeArea int
+}
+
+func DefaultHydrologyOptions() HydrologyOptions {
+	return HydrologyOptions{RiverThreshold: 500, LakeDepth: 0.1, MinLakeArea: 4}
+}
+
+// Hydrology describes how the rain runs off the height map. Every cell
+// drains into one of the eight neighbours (D8) or off the map
+type Hydrology struct {
+	// Filled is the height map with the depressions filled up to their spill points
+	Filled *HeightMap
+	// Receivers keep the index i*jMax+j of the cell every cell drains into,
+	// -1 for the cells draining off the map and the no data cells
+	Receivers []int
+	// Accumulation is the number of cells draining through every cell, the own cell included
+	Accumulation *HeightMap
+	// Mask is the water of every cell
+	Mask []WaterKind
+	// order lists the cells from downstream to upstream
+	order []int
+}
+
+type floodItem struct {
+	idx    int
+	height float32
+}
+
+type floodQueue []floodItem
+
+func (queue floodQueue) Len() int { return len(queue) }
+
+func (queue floodQueue) Less(a, b int) bool { return queue[a].height < queue[b].height }
+
+func (queue floodQueue) Swap(a, b int) { queue[a], queue[b] = queue[b], queue[a] }
+
+func (queue *floodQueue) Push(x interface{}) { *queue = append(*queue, x.(floodItem)) }
+
+func (queue *floodQueue) Pop() interface{} {
+	old := *queue
+	item := old[len(old)-1]
+	*queue = old[:len(old)-1]
+	return item
+}
+
+// NewHydrology fills the depressions with the priority flood, routes every
+// cell down the steepest descent and extracts the rivers and the lakes.
+// The water leaves the map through the edges and the no data cells
+func NewHydrology(heights *HeightMap, options HydrologyOptions) (hydrology *Hydrology) {
+	iMax, jMax := heights.Bounds()
+	hydrology = new(Hydrology)
+	hydrology.Filled = EmptyHeightMap(iMax, jMax)
+	hydrology.Filled.CellSize, hydrology.Filled.Origin, hydrology.Filled.NoData = heights.CellSize, heights.Origin, heights.NoData
+	hydrology.Filled.DegreeStep = heights.DegreeStep
+	copy(hydrology.Filled.Heights, heights.Heights)
+	hydrology.Receivers = make([]int, iMax*jMax)
+	hydrology.order = make([]int, 0, iMax*jMax)
+
+	// The flood grows from the outlets in the order of the height, so the cell
+	// it comes from is the way out of a flat or a depression
+	parents := make([]int, iMax*jMax)
+	done := make([]bool, iMax*jMax)
+	queue := new(floodQueue)
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			idx := i*jMax + j
+			parents[idx] = -1
+			if heights.IsNoData(i, j) {
+				done[idx] = true
+				continue
+			}
+			if i == 0 || j == 0 || i == iMax-1 || j == jMax-1 || touchesNoData(heights, i, j) {
+				done[idx] = true
+				heap.Push(queue, floodItem{idx, heights.At(i, j)})
+			}
+		}
+	}
+	for queue.Len() != 0 {
+		item := heap.Pop(queue).(floodItem)
+		hydrology.order = append(hydrology.order, item.idx)
+		i, j := item.idx/jMax, item.idx%jMax
+		for _, offset := range neighbourOffsets {
+			ni, nj := i+offset[0], j+offset[1]
+			if ni < 0 || nj < 0 || ni >= iMax || nj >= jMax || done[ni*jMax+nj] {
+				continue
+			}
+			idx := ni*jMax + nj
+			done[idx] = true
+			parents[idx] = item.idx
+			height := hydrology.Filled.Heights[idx]
+			if height < item.height {
+				height = item.height
+				hydrology.Filled.Heights[idx] = height
+			}
+			heap.Push(queue, floodItem{idx, height})
+		}
+	}
+
+	// The flood pops the heights in the ascending order, so a receiver always
+	// comes before its cells: the steepest descent goes lower, the parent of a
+	// flat has been popped earlier
+	spacing := float64(heights.Spacing())
+	for _, idx := range hydrology.order {
+		i, j := idx/jMax, idx%jMax
+		height := hydrology.Filled.Heights[idx]
+		receiver, steepest := parents[idx], 0.
+		for _, offset := range neighbourOffsets {
+			ni, nj := i+offset[0], j+offset[1]
+			if ni < 0 || nj < 0 || ni >= iMax || nj >= jMax || heights.IsNoData(ni, nj) {
+				continue
+			}
+			distance := spacing
+			if offset[0] != 0 && offset[1] != 0 {
+				distance *= math.Sqrt2
+			}
+			if slope := float64(height-hydrology.Filled.At(ni, nj)) / distance; slope > steepest {
+				receiver, steepest = ni*jMax+nj, slope
+			}
+		}
+		hydrology.Receivers[idx] = receiver
+	}
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			if heights.IsNoData(i, j) {
+				hydrology.Receivers[i*jMax+j] = -1
+			}
+		}
+	}
+
+	hydrology.accumulate(iMax, jMax)
+	hydrology.extract(heights, options)
+	return
+}
+
+// touchesNoData tells whether the water can leave the cell into a no data neighbour
+func touchesNoData(heights *HeightMap, i, j int) bool {
+	if heights.NoData == nil {
+		return false
+	}
+	for _, offset := range neighbourOffsets {
+		if heights.IsNoData(i+offset[0], j+offset[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (hydrology *Hydrology) accumulate(iMax, jMax int) {
+	hydrology.Accumulation = EmptyHeightMap(iMax, jMax)
+	hydrology.Accumulation.CellSize, hydrology.Accumulation.Origin = hydrology.Filled.CellSize, hydrology.Filled.Origin
+	hydrology.Accumulation.DegreeStep = hydrology.Filled.DegreeStep
+	accumulation := hydrology.Accumulation.Heights
+	for idx := len(hydrology.order) - 1; idx >= 0; idx-- {
+		cell := hydrology.order[idx]
+		accumulation[cell]++
+		if receiver := hydrology.Receivers[cell]; receiver != -1 {
+			accumulation[receiver] += accumulation[cell]
+		}
+	}
+}
+
+func (hydrology *Hydrology) extract(heights *HeightMap, options HydrologyOptions) {
+	iMax, jMax := heights.Bounds()
+	hydrology.Mask = make([]WaterKind, iMax*jMax)
+	for idx := range hydrology.Mask {
+		if hydrology.Filled.Heights[idx]-heights.Heights[idx] >= options.LakeDepth && options.LakeDepth > 0 {
+			hydrology.Mask[idx] = LakeCell
+		}
+	}
+
+	// The lakes too small are left dry, the flood fill goes over the sides only
+	seen := make([]bool, iMax*jMax)
+	var lake []int
+	for start := range hydrology.Mask {
+		if hydrology.Mask[start] != LakeCell || seen[start] {
+			continue
+		}
+		seen[start] = true
+		lake = append(lake[:0], start)
+		for next := 0; next < len(lake); next++ {
+			i, j := lake[next]/jMax, lake[next]%jMax
+			for _, offset := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				ni, nj := i+offset[0], j+offset[1]
+				if ni < 0 || nj < 0 || ni >= iMax || nj >= jMax {
+					continue
+				}
+				if idx := ni*jMax + nj; hydrology.Mask[idx] == LakeCell && !seen[idx] {
+					seen[idx] = true
+					lake = append(lake, idx)
+				}
+			}
+		}
+		if len(lake) < options.MinLakeArea {
+			for _, idx := range lake {
+				hydrology.Mask[idx] = DryCell
+			}
+		}
+	}
+
+	for idx, accumulation := range hydrology.Accumulation.Heights {
+		if hydrology.Mask[idx] == DryCell && accumulation >= options.RiverThreshold {
+			hydrology.Mask[idx] = RiverCell
+		}
+	}
+}
+
+// Water returns the water of the cell
+func (hydrology *Hydrology) Water(i, j int) WaterKind {
+	_, jMax := hydrology.Filled.Bounds()
+	return hydrology.Mask[i*jMax+j]
+}
+
+// Paint draws the rivers and the lakes over the texture. The colours listed
+// in the vehicle profile make the water impassable or slow
+func (hydrology *Hydrology) Paint(rgba *image.RGBA, river, lake color.RGBA) {
+	iMax, jMax := hydrology.Filled.Bounds()
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			switch hydrology.Water(i, j) {
+			case RiverCell:
+				rgba.SetRGBA(i, j, river)
+			case LakeCell:
+				rgba.SetRGBA(i, j, lake)
+			}
+		}
+	}
+}
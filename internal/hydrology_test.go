@@ -0,0 +1,88 @@
+package internal
+
+import "testing"
+
+// pitsHeightMap slopes down to the west edge with a pit of one cell and a
+// pit of 3x3 cells dug 2 deep
+func pitsHeightMap() *HeightMap {
+	hm := EmptyHeightMap(12, 7)
+	hm.CellSize = 1
+	for i := 0; i < 12; i++ {
+		for j := 0; j < 7; j++ {
+			hm.SetAt(i, j, 10+float32(i))
+		}
+	}
+	hm.SetAt(3, 3, hm.At(3, 3)-2)
+	for i := 7; i < 10; i++ {
+		for j := 2; j < 5; j++ {
+			hm.SetAt(i, j, 15)
+		}
+	}
+	return hm
+}
+
+func TestHydrologyDrains(t *testing.T) {
+	hm := randomHeightMap(9, 16, 11)
+	noData := float32(-9999)
+	hm.NoData = &noData
+	hm.SetAt(6, 5, noData)
+	hydrology := NewHydrology(hm, DefaultHydrologyOptions())
+	iMax, jMax := hm.Bounds()
+	cells, drained := 0, float32(0)
+	for idx, receiver := range hydrology.Receivers {
+		if hm.IsNoData(idx/jMax, idx%jMax) {
+			continue
+		}
+		cells++
+		if receiver == -1 {
+			drained += hydrology.Accumulation.Heights[idx]
+		}
+		// The filled depressions let every cell run downhill off the map
+		steps := 0
+		for cell := idx; hydrology.Receivers[cell] != -1; cell = hydrology.Receivers[cell] {
+			if next := hydrology.Receivers[cell]; hydrology.Filled.Heights[next] > hydrology.Filled.Heights[cell] {
+				t.Fatalf("the cell %d drains uphill into %d", cell, next)
+			}
+			if steps++; steps > iMax*jMax {
+				t.Fatalf("the cell %d drains in a loop", idx)
+			}
+		}
+		if hydrology.Filled.Heights[idx] < hm.Heights[idx] {
+			t.Errorf("the cell %d is filled below its height", idx)
+		}
+	}
+	// Every cell leaves the map through one of the outlets
+	if drained != float32(cells) {
+		t.Errorf("%g cells drain off the map out of %d", drained, cells)
+	}
+}
+
+func TestHydrologyLakes(t *testing.T) {
+	cases := []struct {
+		name        string
+		minLakeArea int
+		small, big  WaterKind
+	}{
+		{"every pit", 1, LakeCell, LakeCell},
+		{"big pit", 4, DryCell, LakeCell},
+		{"no pit", 10, DryCell, DryCell},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hydrology := NewHydrology(pitsHeightMap(), HydrologyOptions{RiverThreshold: 1000, LakeDepth: 1, MinLakeArea: c.minLakeArea})
+			if water := hydrology.Water(3, 3); water != c.small {
+				t.Errorf("the pit of one cell is %d instead of %d", water, c.small)
+			}
+			for i := 7; i < 10; i++ {
+				for j := 2; j < 5; j++ {
+					if water := hydrology.Water(i, j); water != c.big {
+						t.Errorf("(%d, %d) of the big pit is %d instead of %d", i, j, water, c.big)
+					}
+				}
+			}
+			if water := hydrology.Water(5, 1); water != DryCell {
+				t.Errorf("the slope is %d", water)
+			}
+		})
+	}
+}
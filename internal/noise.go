@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/aquilax/go-perlin"
+)
+
+// Octave is a single term of the height sum. The noise is stretched by
+// Frequency along i and by Frequency*Anisotropy along j
+type Octave struct {
+	Frequency float64
+	Amplitude float64
+	// Anisotropy is 1 if unset
+	Anisotropy float64 `json:",omitempty"`
+}
+
+// UnmarshalFlag parses octaves given as "frequency,amplitude[,anisotropy]" on the command line
+func (octave *Octave) UnmarshalFlag(value string) error {
+	octave.Anisotropy = 0
+	count, _ := fmt.Sscanf(value, "%g,%g,%g", &octave.Frequency, &octave.Amplitude, &octave.Anisotropy)
+	if count < 2 {
+		return fmt.Errorf("octave %q must look like frequency,amplitude[,anisotropy]", value)
+	}
+	return nil
+}
+
+func (octave Octave) MarshalFlag() (string, error) {
+	return fmt.Sprintf("%g,%g,%g", octave.Frequency, octave.Amplitude, octave.Anisotropy), nil
+}
+
+type NoiseVariant string
+
+const (
+	// FBMNoise sums the plain Perlin noise
+	FBMNoise NoiseVariant = "fbm"
+	// RidgedNoise folds every term into sharp crests
+	RidgedNoise NoiseVariant = "ridged"
+	// BillowNoise folds every term into round hills
+	BillowNoise NoiseVariant = "billow"
+)
+
+// NoiseGenerator describes a height map as a sum of the Perlin noise octaves.
+// The same configuration and seed always give the same map
+type NoiseGenerator struct {
+	Seed    int64
+	Octaves []Octave
+	// Persistence is the weight of every next harmonic of the Perlin noise
+	// inside an octave, Lacunarity is its frequency multiplier
+	Persistence float64
+	Lacunarity  float64
+	Harmonics   int32
+	Variant     NoiseVariant `json:",omitempty"`
+	// Scale multiplies the heights, the width of the map over 50 if unset.
+	// The width of a chunked world is CellsPerUnit
+	Scale float64 `json:",omitempty"`
+	// CellsPerUnit is the number of world cells per unit of the noise space, 1000 if unset
+	CellsPerUnit float64 `json:",omitempty"`
+}
+
+// DefaultNoiseGenerator returns the configuration GenerateHeightMap has always used
+func DefaultNoiseGenerator(seed int64) *NoiseGenerator {
+	return &NoiseGenerator{
+		Seed: seed,
+		Octaves: []Octave{
+			{Frequency: 1, Amplitude: 15},
+			{Frequency: 2, Amplitude: 7},
+			{Frequency: 4, Amplitude: 3, Anisotropy: 0.5},
+			{Frequency: 2, Amplitude: 3, Anisotropy: 2},
+			{Frequency: 7, Amplitude: 1},
+			{Frequency: 10, Amplitude: 3, Anisotropy: 0.8},
+		},
+		Persistence: 0.25,
+		Lacunarity:  2,
+		Harmonics:   7,
+		Variant:     FBMNoise,
+	}
+}
+
+// Validate checks the configuration before anything is generated
+func (generator *NoiseGenerator) Validate() error {
+	if generator.Persistence <= 0 {
+		return fmt.Errorf("persistence must be positive, got %g", generator.Persistence)
+	}
+	if generator.Harmonics <= 0 {
+		return fmt.Errorf("harmonics must be positive, got %d", generator.Harmonics)
+	}
+	switch generator.Variant {
+	case "", FBMNoise, RidgedNoise, BillowNoise:
+	default:
+		return fmt.Errorf("unknown noise variant %q", generator.Variant)
+	}
+	return nil
+}
+
+// noise returns the function summing the octaves at the point of the noise space
+func (generator *NoiseGenerator) noise() func(x, y float64) float32 {
+	p := perlin.NewPerlin(1/generator.Persistence, generator.Lacunarity, generator.Harmonics, generator.Seed)
+	fold := func(value float64) float64 { return value }
+	switch generator.Variant {
+	case RidgedNoise:
+		fold = func(value float64) float64 { return 1 - 2*math.Abs(value) }
+	case BillowNoise:
+		fold = func(value float64) float64 { return 2*math.Abs(value) - 1 }
+	}
+	return func(x, y float64) (height float32) {
+		for _, octave := range generator.Octaves {
+			anisotropy := octave.Anisotropy
+			if anisotropy == 0 {
+				anisotropy = 1
+			}
+			height += float32(octave.Amplitude) * float32(fold(p.Noise2D(octave.Frequency*x, octave.Frequency*anisotropy*y)))
+		}
+		return
+	}
+}
+
+// Generate fills the height map, the map spans the unit square of the noise space
+func (generator *NoiseGenerator) Generate(iMax, jMax int) (heights *HeightMap) {
+	heights = EmptyHeightMap(iMax, jMax)
+	fillHeights(heights, generator.noise(), heightScale(generator.Scale, jMax), func(i, j int) (x, y float64) {
+		return float64(i)/float64(iMax) - 0.5, float64(j)/float64(jMax) - 0.5
+	})
+	return
+}
+
+// GenerateRegion fills the window of the unbounded world starting at the
+// world cell (iFrom, jFrom). The heights of a world cell never depend on the
+// window, so the regions sampled separately line up
+func (generator *NoiseGenerator) GenerateRegion(iFrom, jFrom, iMax, jMax int) (heights *HeightMap) {
+	return generateRegion(generator.noise(), generator.Scale, generator.CellsPerUnit, iFrom, jFrom, iMax, jMax)
+}
+
+// GenerateChunk returns the chunk (cx, cy) of the world split into squares of
+// the size. The neighbour chunks continue each other without seams; a mesh
+// needing the shared edge samples can take GenerateRegion one cell larger
+func (generator *NoiseGenerator) GenerateChunk(cx, cy, size int) (heights *HeightMap) {
+	return generator.GenerateRegion(cx*size, cy*size, size, size)
+}
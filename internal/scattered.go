@@ -0,0 +1,299 @@
+package internal
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Point is a surveyed height, X grows to the east and Y to the north
+type Point struct {
+	X, Y, Z float64
+}
+
+// ReadXYZ reads the points from the CSV with x, y and z in the first three
+// columns, the other columns are ignored. The first row is skipped as the
+// header if none of its three columns is a number. The values may be
+// separated by commas, semicolons or tabs and must be finite
+func ReadXYZ(reader io.Reader) (points []Point, err error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return
+	}
+	text := string(data)
+	csvReader := csv.NewReader(strings.NewReader(text))
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	firstLine, _, _ := strings.Cut(text, "\n")
+	for _, separator := range []rune{';', '\t'} {
+		if !strings.ContainsRune(firstLine, ',') && strings.ContainsRune(firstLine, separator) {
+			csvReader.Comma = separator
+		}
+	}
+
+	for line := 1; ; line++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("line %d: %d columns instead of x, y and z", line, len(record))
+		}
+		var values [3]float64
+		var parseErr error
+		parsed := 0
+		for idx := range values {
+			if values[idx], err = strconv.ParseFloat(strings.TrimSpace(record[idx]), 64); err != nil {
+				parseErr = err
+			} else {
+				parsed++
+			}
+		}
+		if parseErr != nil {
+			if line == 1 && parsed == 0 {
+				// The header has none of the three columns a number
+				continue
+			}
+			return nil, fmt.Errorf("line %d: %w", line, parseErr)
+		}
+		for _, value := range values {
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				return nil, fmt.Errorf("line %d: the value %g is not finite", line, value)
+			}
+		}
+		points = append(points, Point{values[0], values[1], values[2]})
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no points")
+	}
+	return
+}
+
+type InterpolationMethod string
+
+const (
+	// IDWInterpolation weights the points closer than the MaxDistance by the
+	// inverse distance, the weights fade to zero at the MaxDistance
+	IDWInterpolation InterpolationMethod = "idw"
+	// TINInterpolation is linear over the Delaunay triangles of the points,
+	// the cells outside the convex hull have no data
+	TINInterpolation InterpolationMethod = "tin"
+)
+
+// ScatteredNoData marks the cells too far from the points
+const ScatteredNoData float32 = -9999
+
+type ScatteredOptions struct {
+	// CellSize is the side of a cell in the units of the coordinates
+	CellSize float64
+	Method   InterpolationMethod
+	// Power of the inverse distance weights
+	Power float64
+	// MaxDistance is the farthest a cell may be from the closest point, 5
+	// cells if unset. The farther cells have no data and so are impassable
+	MaxDistance float64
+}
+
+func DefaultScatteredOptions(cellSize float64) ScatteredOptions {
+	return ScatteredOptions{CellSize: cellSize, Method: TINInterpolation, Power: 2}
+}
+
+func (options ScatteredOptions) Validate() error {
+	if options.CellSize <= 0 {
+		return fmt.Errorf("cell size must be positive, got %g", options.CellSize)
+	}
+	if options.MaxDistance < 0 {
+		return fmt.Errorf("max distance must not be negative, got %g", options.MaxDistance)
+	}
+	switch options.Method {
+	case IDWInterpolation, TINInterpolation:
+	default:
+		return fmt.Errorf("unknown interpolation %q", options.Method)
+	}
+	return nil
+}
+
+// pointIndex buckets the points into squares of the side, so the points
+// closer than the side to a spot are in the 3x3 buckets around it
+type pointIndex struct {
+	points     []Point
+	minX, minY float64
+	side       float64
+	columns    int
+	buckets    map[int][]int
+}
+
+func newPointIndex(points []Point, minX, minY, maxX, side float64) *pointIndex {
+	index := &pointIndex{points: points, minX: minX, minY: minY, side: side, buckets: map[int][]int{}}
+	index.columns = int((maxX-minX)/side) + 1
+	for idx, point := range points {
+		key := index.key(int((point.X-minX)/side), int((point.Y-minY)/side))
+		index.buckets[key] = append(index.buckets[key], idx)
+	}
+	return index
+}
+
+func (index *pointIndex) key(column, row int) int {
+	return row*index.columns + column
+}
+
+// near calls the function for every point closer than the side
+func (index *pointIndex) near(x, y float64, fcn func(idx int, distance float64)) {
+	column, row := int(math.Floor((x-index.minX)/index.side)), int(math.Floor((y-index.minY)/index.side))
+	for dRow := -1; dRow <= 1; dRow++ {
+		for dColumn := -1; dColumn <= 1; dColumn++ {
+			if column+dColumn < 0 || column+dColumn >= index.columns {
+				continue
+			}
+			for _, idx := range index.buckets[index.key(column+dColumn, row+dRow)] {
+				point := index.points[idx]
+				if distance := math.Hypot(point.X-x, point.Y-y); distance <= index.side {
+					fcn(idx, distance)
+				}
+			}
+		}
+	}
+}
+
+// dedupe averages the heights of the points at the same spot
+func dedupe(points []Point) (result []Point) {
+	sorted := append([]Point{}, points...)
+	sort.Slice(sorted, func(a, b int) bool {
+		if sorted[a].X != sorted[b].X {
+			return sorted[a].X < sorted[b].X
+		}
+		return sorted[a].Y < sorted[b].Y
+	})
+	for from := 0; from < len(sorted); {
+		to, sum := from, 0.
+		for ; to < len(sorted) && sorted[to].X == sorted[from].X && sorted[to].Y == sorted[from].Y; to++ {
+			sum += sorted[to].Z
+		}
+		result = append(result, Point{sorted[from].X, sorted[from].Y, sum / float64(to-from)})
+		from = to
+	}
+	return
+}
+
+// Rasterize interpolates the points onto the grid covering them. The cell
+// (0, 0) is at the north west, its outer corner is the Origin
+func Rasterize(points []Point, options ScatteredOptions) (hm *HeightMap, err error) {
+	if err = options.Validate(); err != nil {
+		return
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no points")
+	}
+	points = dedupe(points)
+	minX, minY, maxX, maxY := points[0].X, points[0].Y, points[0].X, points[0].Y
+	for _, point := range points {
+		minX, maxX = minFloat(minX, point.X), maxFloat(maxX, point.X)
+		minY, maxY = minFloat(minY, point.Y), maxFloat(maxY, point.Y)
+	}
+	cellSize := options.CellSize
+	iMax, jMax := int(math.Ceil((maxX-minX)/cellSize)), int(math.Ceil((maxY-minY)/cellSize))
+	if iMax < 1 {
+		iMax = 1
+	}
+	if jMax < 1 {
+		jMax = 1
+	}
+
+	hm = EmptyHeightMap(iMax, jMax)
+	hm.CellSize = float32(cellSize)
+	hm.Origin = &[2]float64{minX, maxY}
+	noData := ScatteredNoData
+	hm.NoData = &noData
+	center := func(i, j int) (x, y float64) {
+		return minX + (float64(i)+0.5)*cellSize, maxY - (float64(j)+0.5)*cellSize
+	}
+
+	maxDistance := options.MaxDistance
+	if maxDistance == 0 {
+		maxDistance = 5 * cellSize
+	}
+	index := newPointIndex(points, minX, minY, maxX, maxDistance)
+	covered := make([]bool, iMax*jMax)
+	ParallelFor(0, iMax, 1, func(i int) {
+		for j := 0; j < jMax; j++ {
+			x, y := center(i, j)
+			index.near(x, y, func(int, float64) { covered[i*jMax+j] = true })
+		}
+	})
+
+	switch options.Method {
+	case IDWInterpolation:
+		ParallelFor(0, iMax, 1, func(i int) {
+			for j := 0; j < jMax; j++ {
+				x, y := center(i, j)
+				var sum, weights float64
+				exact := false
+				index.near(x, y, func(idx int, distance float64) {
+					switch {
+					case exact:
+					case distance == 0:
+						sum, weights, exact = points[idx].Z, 1, true
+					default:
+						// The weights of Shepard fading out by the max distance
+						weight := math.Pow((maxDistance-distance)/(maxDistance*distance), options.Power)
+						sum, weights = sum+weight*points[idx].Z, weights+weight
+					}
+				})
+				if weights == 0 {
+					covered[i*jMax+j] = false
+					continue
+				}
+				hm.SetAt(i, j, float32(sum/weights))
+			}
+		})
+	case TINInterpolation:
+		filled := make([]bool, iMax*jMax)
+		x, y := make([]float64, len(points)), make([]float64, len(points))
+		for idx, point := range points {
+			// Relative coordinates keep the predicates precise
+			x[idx], y[idx] = point.X-minX, point.Y-minY
+		}
+		for _, t := range triangulate(x, y) {
+			a, b, c := points[t[0]], points[t[1]], points[t[2]]
+			area := orient(a.X, a.Y, b.X, b.Y, c.X, c.Y)
+			if area == 0 {
+				continue
+			}
+			iFrom := clampIndex(int(math.Floor((minFloat(a.X, minFloat(b.X, c.X))-minX)/cellSize-0.5)), iMax)
+			iTo := clampIndex(int(math.Ceil((maxFloat(a.X, maxFloat(b.X, c.X))-minX)/cellSize-0.5)), iMax)
+			jFrom := clampIndex(int(math.Floor((maxY-maxFloat(a.Y, maxFloat(b.Y, c.Y)))/cellSize-0.5)), jMax)
+			jTo := clampIndex(int(math.Ceil((maxY-minFloat(a.Y, minFloat(b.Y, c.Y)))/cellSize-0.5)), jMax)
+			for i := iFrom; i <= iTo; i++ {
+				for j := jFrom; j <= jTo; j++ {
+					px, py := center(i, j)
+					// The barycentric weights, a cell on a shared edge takes either triangle
+					wa := orient(b.X, b.Y, c.X, c.Y, px, py) / area
+					wb := orient(c.X, c.Y, a.X, a.Y, px, py) / area
+					wc := 1 - wa - wb
+					if wa < 0 || wb < 0 || wc < 0 {
+						continue
+					}
+					hm.SetAt(i, j, float32(wa*a.Z+wb*b.Z+wc*c.Z))
+					filled[i*jMax+j] = true
+				}
+			}
+		}
+		for idx := range covered {
+			covered[idx] = covered[idx] && filled[idx]
+		}
+	}
+
+	for idx := range covered {
+		if !covered[idx] {
+			hm.Heights[idx] = ScatteredNoData
+		}
+	}
+	return
+}
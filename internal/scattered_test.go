@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestReadXYZ(t *testing.T) {
+	cases := []struct {
+		name   string
+		text   string
+		points []Point
+		fails  bool
+	}{
+		{"commas", "1,2,3\n4,5,6\n", []Point{{1, 2, 3}, {4, 5, 6}}, false},
+		{"header and extra columns", "x;y;z;class\n1;2;3;7\n", []Point{{1, 2, 3}}, false},
+		{"tabs", "1\t2\t3\n", []Point{{1, 2, 3}}, false},
+		{"short row", "1,2,3\n4,5\n", nil, true},
+		{"not a number", "1,2,3\n4,5,six\n", nil, true},
+		{"nan", "1,2,3\n4,NaN,6\n", nil, true},
+		{"infinite", "1,2,3\n4,5,+Inf\n", nil, true},
+		{"no points", "x,y,z\n", nil, true},
+		// A broken first point is not taken for a header
+		{"broken first point", "1,2,x\n4,5,6\n", nil, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			points, err := ReadXYZ(strings.NewReader(c.text))
+			if c.fails {
+				if err == nil {
+					t.Errorf("read %v instead of failing", points)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(points) != len(c.points) {
+				t.Fatalf("read %v instead of %v", points, c.points)
+			}
+			for idx := range points {
+				if points[idx] != c.points[idx] {
+					t.Errorf("read %v instead of %v", points, c.points)
+				}
+			}
+		})
+	}
+}
+
+// scatter returns the points of the plane, randomly, on a grid or offset by
+// the size of the UTM coordinates
+func scatter(kind string) (points []Point, plane func(x, y float64) float64) {
+	x0, y0 := 0., 0.
+	if kind == "utm" {
+		x0, y0 = 512345.5, 5432109.25
+	}
+	plane = func(x, y float64) float64 { return 0.3*(x-x0) - 0.2*(y-y0) + 40 }
+	r := rand.New(rand.NewSource(5))
+	for k := 0; k < 100; k++ {
+		x, y := 10*float64(k%10), 10*float64(k/10)
+		if kind != "grid" {
+			x, y = 90*r.Float64(), 90*r.Float64()
+		}
+		points = append(points, Point{x0 + x, y0 + y, plane(x0+x, y0+y)})
+	}
+	return
+}
+
+func TestRasterizeTINPlane(t *testing.T) {
+	for _, kind := range []string{"random", "grid", "utm"} {
+		t.Run(kind, func(t *testing.T) {
+			points, plane := scatter(kind)
+			hm, err := Rasterize(points, ScatteredOptions{CellSize: 1, Method: TINInterpolation, MaxDistance: 30})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The middle is inside the hull of any of the sets
+			iMax, jMax := hm.Bounds()
+			if hm.IsNoData(iMax/2, jMax/2) {
+				t.Fatal("the middle of the points has no data")
+			}
+			for i := 0; i < iMax; i++ {
+				for j := 0; j < jMax; j++ {
+					if hm.IsNoData(i, j) {
+						continue
+					}
+					x, y := hm.Coordinates(float64(i), float64(j))
+					if want := plane(x, y); math.Abs(float64(hm.At(i, j))-want) > 1e-3 {
+						t.Fatalf("(%d, %d) is %g instead of %g of the plane", i, j, hm.At(i, j), want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRasterizeIDW(t *testing.T) {
+	// The middle points are at the centres of the cells (10, 10) and (12, 10)
+	points := []Point{{0, 0, 10}, {20, 20, 20}, {10.5, 9.5, 42}, {12.5, 9.5, 2}}
+	hm, err := Rasterize(points, ScatteredOptions{CellSize: 1, Method: IDWInterpolation, Power: 2, MaxDistance: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hm.At(10, 10) != 42 || hm.At(12, 10) != 2 {
+		t.Errorf("the cells of the points are %g and %g instead of 42 and 2", hm.At(10, 10), hm.At(12, 10))
+	}
+	// The cell halfway between them weighs them the same
+	if math.Abs(float64(hm.At(11, 10))-22) > 1e-4 {
+		t.Errorf("the cell between the points is %g instead of 22", hm.At(11, 10))
+	}
+	// The centre (16.5, 2.5) is farther than 5 from every point
+	if !hm.IsNoData(16, 17) {
+		t.Errorf("the cell beyond the max distance is %g", hm.At(16, 17))
+	}
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"math"
+	"math/rand"
+)
+
+// simplex is the 2D simplex noise of Ken Perlin as described by Stefan
+// Gustavson, the permutation is shuffled by the seed
+type simplex struct {
+	perm [512]uint8
+}
+
+var simplexGradients = [12][2]float64{
+	{1, 1}, {-1, 1}, {1, -1}, {-1, -1},
+	{1, 0}, {-1, 0}, {1, 0}, {-1, 0},
+	{0, 1}, {0, -1}, {0, 1}, {0, -1},
+}
+
+var (
+	simplexSkew   = 0.5 * (math.Sqrt(3) - 1)
+	simplexUnskew = (3 - math.Sqrt(3)) / 6
+)
+
+func newSimplex(seed int64) (noise *simplex) {
+	noise = new(simplex)
+	r := rand.New(rand.NewSource(seed))
+	for idx, value := range r.Perm(256) {
+		noise.perm[idx], noise.perm[idx+256] = uint8(value), uint8(value)
+	}
+	return
+}
+
+// noise2D returns the noise in [-1, 1]
+func (noise *simplex) noise2D(x, y float64) float64 {
+	// The cell of the skewed grid and the offsets from its three corners
+	s := (x + y) * simplexSkew
+	i, j := math.Floor(x+s), math.Floor(y+s)
+	t := (i + j) * simplexUnskew
+	x0, y0 := x-(i-t), y-(j-t)
+	i1, j1 := 0, 1
+	if x0 > y0 {
+		i1, j1 = 1, 0
+	}
+	x1, y1 := x0-float64(i1)+simplexUnskew, y0-float64(j1)+simplexUnskew
+	x2, y2 := x0-1+2*simplexUnskew, y0-1+2*simplexUnskew
+
+	ii, jj := int(i)&255, int(j)&255
+	corner := func(x, y float64, gradient int) float64 {
+		t := 0.5 - x*x - y*y
+		if t < 0 {
+			return 0
+		}
+		g := simplexGradients[gradient%12]
+		t *= t
+		return t * t * (g[0]*x + g[1]*y)
+	}
+	return 70 * (corner(x0, y0, int(noise.perm[ii+int(noise.perm[jj])])) +
+		corner(x1, y1, int(noise.perm[ii+i1+int(noise.perm[jj+j1])])) +
+		corner(x2, y2, int(noise.perm[ii+1+int(noise.perm[jj+1])])))
+}
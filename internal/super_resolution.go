@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// SuperResolution raises the resolution of a coarse height map and adds the
+// fractal detail the coarse cells can not show. The coarse cell (i, j)
+// becomes the cell (i*Factor, j*Factor) keeping its height exactly, so the
+// (n-1)*Factor+1 cells span the same ground as the n coarse ones.
+//
+// The cells in between are refined by the midpoint displacement. The noise
+// follows the fractional Brownian motion of the Hurst exponent measured on the
+// coarse map, and its amplitude follows the local roughness, so the flat
+// plains stay flat and the rough hills get rougher
+type SuperResolution struct {
+	// Factor is 2, 4 or 8
+	Factor int
+	Seed   int64
+	// Hurst is the exponent of the detail between 0 and 1, the higher the
+	// smoother. It is estimated from the map if unset
+	Hurst float64 `json:",omitempty"`
+	// Roughness multiplies the amplitude of the detail, 0 gives a plain interpolation
+	Roughness float64
+}
+
+func DefaultSuperResolution(factor int, seed int64) *SuperResolution {
+	return &SuperResolution{Factor: factor, Seed: seed, Roughness: 1}
+}
+
+func (sr *SuperResolution) Validate() error {
+	switch sr.Factor {
+	case 2, 4, 8:
+	default:
+		return fmt.Errorf("factor must be 2, 4 or 8, got %d", sr.Factor)
+	}
+	if sr.Hurst < 0 || sr.Hurst >= 1 {
+		return fmt.Errorf("hurst exponent must be between 0 and 1, got %g", sr.Hurst)
+	}
+	if sr.Roughness < 0 {
+		return fmt.Errorf("roughness must not be negative, got %g", sr.Roughness)
+	}
+	return nil
+}
+
+// EstimateHurst compares the height differences of the neighbour cells and
+// of the cells two apart, as the differences grow with the distance to the
+// power of the exponent. It is clamped to [0.1, 0.9]
+func EstimateHurst(hm *HeightMap) float64 {
+	iMax, jMax := hm.Bounds()
+	var near, far float64
+	var nearCount, farCount int
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			if hm.IsNoData(i, j) {
+				continue
+			}
+			for _, lag := range [2]int{1, 2} {
+				for _, offset := range [2][2]int{{lag, 0}, {0, lag}} {
+					ni, nj := i+offset[0], j+offset[1]
+					if ni >= iMax || nj >= jMax || hm.IsNoData(ni, nj) {
+						continue
+					}
+					delta := float64(hm.At(ni, nj) - hm.At(i, j))
+					if lag == 1 {
+						near, nearCount = near+delta*delta, nearCount+1
+					} else {
+						far, farCount = far+delta*delta, farCount+1
+					}
+				}
+			}
+		}
+	}
+	if near == 0 || far == 0 {
+		return 0.5
+	}
+	hurst := 0.5 * math.Log2((far/float64(farCount))/(near/float64(nearCount)))
+	return math.Max(0.1, math.Min(0.9, hurst))
+}
+
+// roughness returns the RMS of the height differences between every coarse
+// cell and its measured neighbours
+func roughness(hm *HeightMap) []float64 {
+	iMax, jMax := hm.Bounds()
+	result := make([]float64, iMax*jMax)
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			if hm.IsNoData(i, j) {
+				continue
+			}
+			sum, count := 0., 0
+			for _, offset := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				ni, nj := i+offset[0], j+offset[1]
+				if ni < 0 || nj < 0 || ni >= iMax || nj >= jMax || hm.IsNoData(ni, nj) {
+					continue
+				}
+				delta := float64(hm.At(ni, nj) - hm.At(i, j))
+				sum, count = sum+delta*delta, count+1
+			}
+			if count != 0 {
+				result[i*jMax+j] = math.Sqrt(sum / float64(count))
+			}
+		}
+	}
+	return result
+}
+
+// Upsample returns the refined height map. A fine cell next to a coarse no
+// data cell has no data. The centre of the cell (0, 0) stays in place, so
+// the Origin, the outer corner of the smaller cell, moves inwards
+func (sr *SuperResolution) Upsample(coarse *HeightMap) (fine *HeightMap) {
+	iCoarse, jCoarse := coarse.Bounds()
+	f := sr.Factor
+	iMax, jMax := (iCoarse-1)*f+1, (jCoarse-1)*f+1
+	fine = EmptyHeightMap(iMax, jMax)
+	fine.CellSize = coarse.Spacing() / float32(f)
+	fine.DegreeStep = coarse.DegreeStep / float64(f)
+	if coarse.Origin != nil {
+		shift := coarse.originStep() * float64(f-1) / float64(2*f)
+		fine.Origin = &[2]float64{coarse.Origin[0] + shift, coarse.Origin[1] - shift}
+	}
+	fine.NoData = coarse.NoData
+	noData := make([]bool, iMax*jMax)
+	for i := 0; i < iCoarse; i++ {
+		for j := 0; j < jCoarse; j++ {
+			fine.SetAt(i*f, j*f, coarse.At(i, j))
+			noData[i*f*jMax+j*f] = coarse.IsNoData(i, j)
+		}
+	}
+
+	hurst := sr.Hurst
+	if hurst == 0 {
+		hurst = EstimateHurst(coarse)
+	}
+	coarseRoughness := roughness(coarse)
+	// localRoughness interpolates the roughness of the coarse cells around the fine one
+	localRoughness := func(i, j int) float64 {
+		ci, cj := i/f, j/f
+		u, v := float64(i%f)/float64(f), float64(j%f)/float64(f)
+		at := func(i, j int) float64 {
+			return coarseRoughness[clampIndex(i, iCoarse)*jCoarse+clampIndex(j, jCoarse)]
+		}
+		return at(ci, cj)*(1-u)*(1-v) + at(ci+1, cj)*u*(1-v) + at(ci, cj+1)*(1-u)*v + at(ci+1, cj+1)*u*v
+	}
+
+	r := rand.New(rand.NewSource(sr.Seed))
+	// The midpoint deviates from the mean of its ends by this share of the
+	// differences at its level in the fractional Brownian motion
+	deviation := sr.Roughness * math.Sqrt(1-math.Pow(2, 2*hurst-2))
+	level := 0
+	// refine sets the cell to the mean of the neighbours at the distance plus the noise
+	refine := func(i, j, half int, offsets [4][2]int) {
+		sum, count := 0., 0
+		idx := i*jMax + j
+		for _, offset := range offsets {
+			ni, nj := i+offset[0]*half, j+offset[1]*half
+			if ni < 0 || nj < 0 || ni >= iMax || nj >= jMax {
+				continue
+			}
+			if noData[ni*jMax+nj] {
+				noData[idx] = true
+			}
+			sum, count = sum+float64(fine.At(ni, nj)), count+1
+		}
+		// The noise is drawn for every cell, so the no data does not shift the detail elsewhere
+		noise := r.NormFloat64() * deviation * localRoughness(i, j) * math.Pow(2, -hurst*float64(level))
+		if noData[idx] {
+			fine.SetAt(i, j, *fine.NoData)
+			return
+		}
+		fine.SetAt(i, j, float32(sum/float64(count)+noise))
+	}
+
+	diagonals := [4][2]int{{-1, -1}, {-1, 1}, {1, -1}, {1, 1}}
+	sides := [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	for step := f; step > 1; step /= 2 {
+		half := step / 2
+		level++
+		// Diamond step: the centre of every square
+		for i := half; i < iMax; i += step {
+			for j := half; j < jMax; j += step {
+				refine(i, j, half, diagonals)
+			}
+		}
+		// Square step: the middle of every edge
+		for i := 0; i < iMax; i += half {
+			for j := (i/half + 1) % 2 * half; j < jMax; j += step {
+				refine(i, j, half, sides)
+			}
+		}
+	}
+	return
+}
+
+// UpsampleRemap takes the texture pixel of the coarse cell closest to the fine one
+func (sr *SuperResolution) UpsampleRemap(iCoarse, jCoarse int) Remap {
+	f := sr.Factor
+	return Remap{IMax: (iCoarse-1)*f + 1, JMax: (jCoarse-1)*f + 1, Source: func(i, j int) (int, int) {
+		return (i + f/2) / f, (j + f/2) / f
+	}}
+}
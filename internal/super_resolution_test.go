@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func randomHeightMap(seed int64, iMax, jMax int) *HeightMap {
+	r := rand.New(rand.NewSource(seed))
+	hm := EmptyHeightMap(iMax, jMax)
+	for idx := range hm.Heights {
+		hm.Heights[idx] = r.Float32() * 100
+	}
+	return hm
+}
+
+func TestUpsampleKeepsCoarseSamples(t *testing.T) {
+	coarse := randomHeightMap(3, 7, 5)
+	coarse.CellSize = 90
+	for _, factor := range []int{2, 4, 8} {
+		for _, roughness := range []float64{0, 1, 3} {
+			sr := DefaultSuperResolution(factor, 11)
+			sr.Roughness = roughness
+			fine := sr.Upsample(coarse)
+			if iMax, jMax := fine.Bounds(); iMax != 6*factor+1 || jMax != 4*factor+1 {
+				t.Fatalf("factor %d: %dx%d fine cells", factor, iMax, jMax)
+			}
+			if remap := sr.UpsampleRemap(coarse.Bounds()); remap.IMax != 6*factor+1 || remap.JMax != 4*factor+1 {
+				t.Errorf("factor %d: the remap of the texture is %dx%d", factor, remap.IMax, remap.JMax)
+			}
+			if fine.Spacing() != 90/float32(factor) {
+				t.Errorf("factor %d: the cell size is %g", factor, fine.Spacing())
+			}
+			for i := 0; i < 7; i++ {
+				for j := 0; j < 5; j++ {
+					if fine.At(i*factor, j*factor) != coarse.At(i, j) {
+						t.Fatalf("factor %d, roughness %g: (%d, %d) is %g instead of %g", factor, roughness, i, j, fine.At(i*factor, j*factor), coarse.At(i, j))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestUpsampleIsSeeded(t *testing.T) {
+	coarse := randomHeightMap(5, 6, 6)
+	first := DefaultSuperResolution(4, 1).Upsample(coarse)
+	sameHeights(t, DefaultSuperResolution(4, 1).Upsample(coarse), first)
+	other := DefaultSuperResolution(4, 2).Upsample(coarse)
+	for idx := range first.Heights {
+		if first.Heights[idx] != other.Heights[idx] {
+			return
+		}
+	}
+	t.Error("another seed gave the same detail")
+}
+
+func TestUpsampleFlat(t *testing.T) {
+	coarse := EmptyHeightMap(4, 4)
+	for idx := range coarse.Heights {
+		coarse.Heights[idx] = 12
+	}
+	// A flat map has no roughness to scale the detail with
+	fine := DefaultSuperResolution(8, 1).Upsample(coarse)
+	for idx, height := range fine.Heights {
+		if height != 12 {
+			t.Fatalf("the cell %d is %g on the flat map", idx, height)
+		}
+	}
+}
+
+func TestUpsampleNoData(t *testing.T) {
+	coarse := randomHeightMap(7, 4, 4)
+	noData := float32(-9999)
+	coarse.NoData = &noData
+	coarse.SetAt(2, 1, noData)
+	fine := DefaultSuperResolution(2, 1).Upsample(coarse)
+	for _, cell := range [][2]int{{4, 2}, {3, 2}, {5, 3}} {
+		if !fine.IsNoData(cell[0], cell[1]) {
+			t.Errorf("(%d, %d) next to no data is %g", cell[0], cell[1], fine.At(cell[0], cell[1]))
+		}
+	}
+	if fine.IsNoData(0, 0) {
+		t.Error("the cell away from no data has no data")
+	}
+}
+
+func TestUpsampleGeoreference(t *testing.T) {
+	coarse := randomHeightMap(2, 4, 3)
+	coarse.CellSize, coarse.Origin = 90, &[2]float64{1000, 5000}
+	fine := DefaultSuperResolution(3, 1).Upsample(coarse)
+	// The centres of the coarse cells are the centres of their fine ones
+	for _, cell := range [][2]int{{0, 0}, {3, 2}} {
+		x, y := coarse.Coordinates(float64(cell[0]), float64(cell[1]))
+		fineX, fineY := fine.Coordinates(float64(3*cell[0]), float64(3*cell[1]))
+		if math.Abs(x-fineX) > 1e-9 || math.Abs(y-fineY) > 1e-9 {
+			t.Errorf("the coarse cell %v is at (%g, %g), the fine one at (%g, %g)", cell, x, y, fineX, fineY)
+		}
+	}
+
+	coarse.Origin, coarse.DegreeStep = &[2]float64{6, 46}, 1.0/1200
+	fine = DefaultSuperResolution(3, 1).Upsample(coarse)
+	if fine.DegreeStep != 1.0/3600 || math.Abs(fine.Origin[0]-6-1.0/3600) > 1e-12 || math.Abs(fine.Origin[1]-46+1.0/3600) > 1e-12 {
+		t.Errorf("the fine origin is %v of the step %g", *fine.Origin, fine.DegreeStep)
+	}
+}
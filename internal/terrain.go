@@ -10,16 +10,16 @@ type Terrain struct {
 	buf *gl2.ArrayBuffer
 }
 
-func NewTerrain(heights *HeightMap) (terrain *Terrain) {
+func NewTerrain(heights Grid) (terrain *Terrain) {
 	terrain = new(Terrain)
-	iMax, jMax := len(heights.Heights)/heights.Stride, heights.Stride
+	iMax, jMax := heights.Bounds()
 	vertices := make([]float32, (iMax-1)*(jMax-1)*6*3)
 	for i := 0; i < iMax-1; i++ {
 		for j := 0; j < jMax-1; j++ {
-			h1 := heights.Heights[i*jMax+j]
-			h2 := heights.Heights[i*jMax+j+1]
-			h3 := heights.Heights[(i+1)*jMax+j+1]
-			h4 := heights.Heights[(i+1)*jMax+j]
+			h1 := heights.At(i, j)
+			h2 := heights.At(i, j+1)
+			h3 := heights.At(i+1, j+1)
+			h4 := heights.At(i+1, j)
 
 			l := (i*(jMax-1) + j) * 6 * 3
 			vertices[l], vertices[l+1], vertices[l+2] = float32(i), h1, float32(j)
@@ -0,0 +1,191 @@
+// Package texture paints the field texture from the height map, so the
+// solvers and terrain-drawer can run on a generated map without a hand-painted one
+package texture
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"terrain/internal"
+	algo "terrain/internal/algorithms"
+	"terrain/internal/analysis"
+	"terrain/internal/common"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Rule paints the cells matching all of its set conditions
+type Rule struct {
+	Name  string
+	Color common.HexColor
+	// MinHeight and MaxHeight bound the height, a share of the height range if the rules are relative
+	MinHeight *float32 `json:",omitempty"`
+	MaxHeight *float32 `json:",omitempty"`
+	// MinSlope and MaxSlope bound the slope in degrees
+	MinSlope *float32 `json:",omitempty"`
+	MaxSlope *float32 `json:",omitempty"`
+	// MaxWaterDistance is the farthest the cell may be from a river or a lake in height map units
+	MaxWaterDistance *float32 `json:",omitempty"`
+}
+
+// Rules describe the texture, the first matching rule paints a cell and the
+// cells no rule matches get the background
+type Rules struct {
+	Background common.HexColor
+	// Relative rules give the heights as a share of the height range, 0 is the lowest cell and 1 is the highest
+	Relative bool
+	// HeightRange is the lowest and the highest height of the relative rules,
+	// the range of the map if unset. The chunks of a world need the same one
+	HeightRange *[2]float32 `json:",omitempty"`
+	// Water extracts the rivers and the lakes before the rules are applied, no water if unset
+	Water      *internal.HydrologyOptions `json:",omitempty"`
+	RiverColor common.HexColor
+	LakeColor  common.HexColor
+	Rules      []Rule
+}
+
+func ptr(value float32) *float32 { return &value }
+
+// DefaultRules use the colours of the default and the bundled profiles: the water is
+// impassable, the marsh is slow and the cliffs are black
+func DefaultRules() *Rules {
+	return &Rules{
+		Background: common.HexColor{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		Relative:   true,
+		Water:      &internal.HydrologyOptions{RiverThreshold: 500, LakeDepth: 0.5, MinLakeArea: 16},
+		RiverColor: common.HexColor{B: 0xff, A: 0xff},
+		LakeColor:  common.HexColor{B: 0xff, A: 0xff},
+		Rules: []Rule{
+			{Name: "cliff", Color: common.HexColor{A: 0xff}, MinSlope: ptr(50)},
+			{Name: "snow", Color: common.HexColor{R: 0xf0, G: 0xf8, B: 0xff, A: 0xff}, MinHeight: ptr(0.9)},
+			{Name: "rock", Color: common.HexColor{R: 0xa0, G: 0x90, B: 0x80, A: 0xff}, MinHeight: ptr(0.75)},
+			{Name: "sand", Color: common.HexColor{R: 0xe0, G: 0xd0, B: 0x80, A: 0xff}, MaxWaterDistance: ptr(2)},
+			{Name: "marsh", Color: common.HexColor{G: 0xff, B: 0xff, A: 0xff}, MaxHeight: ptr(0.2), MaxSlope: ptr(3)},
+		},
+	}
+}
+
+func LoadRules(filePath string) (rules *Rules) {
+	file, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.WithError(err).Panic("failed to load texture rules from file")
+	}
+	rules = DefaultRules()
+	rules.Rules = nil
+	if err = json.Unmarshal(file, rules); err != nil {
+		log.WithError(err).Panic("failed to load texture rules from file")
+	}
+	return
+}
+
+// Validate checks the rules before anything is painted
+func (rules *Rules) Validate() error {
+	for _, rule := range rules.Rules {
+		if rule.MinHeight != nil && rule.MaxHeight != nil && *rule.MinHeight > *rule.MaxHeight {
+			return fmt.Errorf("rule %s: the height range is empty", rule.Name)
+		}
+		if rule.MinSlope != nil && rule.MaxSlope != nil && *rule.MinSlope > *rule.MaxSlope {
+			return fmt.Errorf("rule %s: the slope range is empty", rule.Name)
+		}
+		if rule.MaxWaterDistance != nil && rules.Water == nil {
+			return fmt.Errorf("rule %s: needs the water to be extracted", rule.Name)
+		}
+	}
+	if rules.HeightRange != nil && rules.HeightRange[0] >= rules.HeightRange[1] {
+		return fmt.Errorf("the height range %v is empty", *rules.HeightRange)
+	}
+	return nil
+}
+
+// ValidateChunks checks the rules paint the chunks of a world the same way on
+// both sides of their borders. Every chunk would find its own height range
+// and its own rivers otherwise
+func (rules *Rules) ValidateChunks() error {
+	if rules.Relative && rules.HeightRange == nil {
+		return fmt.Errorf("the relative rules need a HeightRange for the chunks")
+	}
+	if rules.Water != nil {
+		return fmt.Errorf("the water of a chunk does not join the neighbour chunks, the rules for the chunks must have no Water")
+	}
+	return nil
+}
+
+func within(value float32, min, max *float32) bool {
+	return (min == nil || value >= *min) && (max == nil || value <= *max)
+}
+
+// Synthesize paints the texture of the height map size, pixel x is i and
+// pixel y is j. The no data cells are painted black, so they stay impassable
+func (rules *Rules) Synthesize(heights *internal.HeightMap) (rgba *image.RGBA) {
+	iMax, jMax := heights.Bounds()
+	rgba = image.NewRGBA(image.Rect(0, 0, iMax, jMax))
+	slope := analysis.Slope(heights)
+
+	var water *internal.Hydrology
+	var waterDistance []float32
+	if rules.Water != nil {
+		water = internal.NewHydrology(heights, *rules.Water)
+		waterDistance = algo.DistanceTransform(iMax, jMax, func(i, j int) bool {
+			return water.Water(i, j) != internal.DryCell
+		})
+	}
+
+	low, high := float32(0), float32(1)
+	if rules.HeightRange != nil {
+		low, high = rules.HeightRange[0], rules.HeightRange[1]
+	} else if rules.Relative {
+		first := true
+		for i := 0; i < iMax; i++ {
+			for j := 0; j < jMax; j++ {
+				if heights.IsNoData(i, j) {
+					continue
+				}
+				height := heights.At(i, j)
+				if first || height < low {
+					low = height
+				}
+				if first || height > high {
+					high = height
+				}
+				first = false
+			}
+		}
+		if high <= low {
+			high = low + 1
+		}
+	}
+
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			switch {
+			case heights.IsNoData(i, j):
+				rgba.SetRGBA(i, j, common.HexColor{A: 0xff}.RGBA())
+				continue
+			case water != nil && water.Water(i, j) == internal.RiverCell:
+				rgba.SetRGBA(i, j, rules.RiverColor.RGBA())
+				continue
+			case water != nil && water.Water(i, j) == internal.LakeCell:
+				rgba.SetRGBA(i, j, rules.LakeColor.RGBA())
+				continue
+			}
+			height := heights.At(i, j)
+			if rules.Relative {
+				height = (height - low) / (high - low)
+			}
+			color := rules.Background
+			for _, rule := range rules.Rules {
+				if !within(height, rule.MinHeight, rule.MaxHeight) || !within(slope.At(i, j), rule.MinSlope, rule.MaxSlope) {
+					continue
+				}
+				if rule.MaxWaterDistance != nil && waterDistance[i*jMax+j]*heights.Spacing() > *rule.MaxWaterDistance {
+					continue
+				}
+				color = rule.Color
+				break
+			}
+			rgba.SetRGBA(i, j, color.RGBA())
+		}
+	}
+	return
+}
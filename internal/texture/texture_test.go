@@ -0,0 +1,83 @@
+package texture
+
+import (
+	"terrain/internal"
+	"terrain/internal/common"
+	"testing"
+)
+
+var (
+	black = common.HexColor{A: 0xff}
+	red   = common.HexColor{R: 0xff, A: 0xff}
+	green = common.HexColor{G: 0xff, A: 0xff}
+)
+
+func TestSynthesize(t *testing.T) {
+	// Flat in the west, rising by 10 a cell from i = 3 to the east
+	noData := float32(-9999)
+	heights := internal.EmptyHeightMap(6, 3)
+	heights.CellSize, heights.NoData = 1, &noData
+	for i := 3; i < 6; i++ {
+		for j := 0; j < 3; j++ {
+			heights.SetAt(i, j, float32(10*(i-2)))
+		}
+	}
+	heights.SetAt(0, 0, noData)
+	rules := &Rules{
+		Background: common.HexColor{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		Rules: []Rule{
+			{Name: "cliff", Color: black, MinSlope: ptr(50)},
+			{Name: "low", Color: red, MaxHeight: ptr(1)},
+			{Name: "also low", Color: green, MaxHeight: ptr(1)},
+		},
+	}
+	if err := rules.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	rgba := rules.Synthesize(heights)
+	cases := []struct {
+		name  string
+		i, j  int
+		color common.HexColor
+	}{
+		{"no data", 0, 0, black},
+		{"cliff", 4, 1, black},
+		// The low foot of the cliff matches the cliff first
+		{"first match", 2, 1, black},
+		// The slope next to no data is unknown, so only the height rules match
+		{"first of two", 1, 1, red},
+	}
+	for _, c := range cases {
+		if got := rgba.RGBAAt(c.i, c.j); !c.color.Matches(got) {
+			t.Errorf("%s: (%d, %d) is %v instead of %v", c.name, c.i, c.j, got, c.color.RGBA())
+		}
+	}
+}
+
+func TestSynthesizeHeightRange(t *testing.T) {
+	// A flat chunk is the lowest of its own range but high in the world one
+	chunk := internal.EmptyHeightMap(4, 4)
+	for idx := range chunk.Heights {
+		chunk.Heights[idx] = 60
+	}
+	rules := &Rules{
+		Relative: true,
+		Rules:    []Rule{{Name: "high", Color: red, MinHeight: ptr(0.5)}},
+	}
+	if err := rules.ValidateChunks(); err == nil {
+		t.Error("the relative rules without a height range are accepted for the chunks")
+	}
+	if got := rules.Synthesize(chunk).RGBAAt(1, 1); red.Matches(got) {
+		t.Error("the flat map is high in its own range")
+	}
+	rules.HeightRange = &[2]float32{0, 100}
+	if err := rules.ValidateChunks(); err != nil {
+		t.Error(err)
+	}
+	if got := rules.Synthesize(chunk).RGBAAt(1, 1); !red.Matches(got) {
+		t.Errorf("the chunk at 60 of 0..100 is %v", got)
+	}
+	if err := DefaultRules().ValidateChunks(); err == nil {
+		t.Error("the water of the default rules is accepted for the chunks")
+	}
+}
@@ -0,0 +1,334 @@
+package internal
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Grid is the storage of the heights the solvers and the renderers read from.
+// HeightMap keeps all the cells in memory, TiledHeightMap keeps only the
+// recently used tiles
+type Grid interface {
+	Bounds() (iMax, jMax int)
+	// Spacing returns the distance between neighbour cells in height units
+	Spacing() float32
+	At(i, j int) float32
+	SetAt(i, j int, value float32)
+	// IsNoData tells whether the cell has no measurement
+	IsNoData(i, j int) bool
+}
+
+const tiledMetaFile = "tiles.json"
+
+type tiledMeta struct {
+	Rows, Cols int
+	TileSize   int
+	CellSize   float32 `json:",omitempty"`
+	// Fill is the value of the cells of the missing tiles
+	Fill       float32     `json:",omitempty"`
+	Origin     *[2]float64 `json:",omitempty"`
+	DegreeStep float64     `json:",omitempty"`
+	NoData     *float32    `json:",omitempty"`
+}
+
+type tileKey struct {
+	I, J int
+}
+
+type tile struct {
+	key     tileKey
+	heights []float32
+	dirty   bool
+}
+
+// TiledHeightMap stores the heights in a directory of square tiles. A tile
+// is read from the disk on the first access and written back when it is
+// evicted from the LRU cache. Missing tiles are read as zero heights unless
+// another fill value is set.
+//
+// The tile files hold tileSize*tileSize little endian float32 values row by
+// row, the edge tiles are padded.
+//
+// Only the heights and the labels of OutOfCoreDijkstra are tiled. The texture
+// takes 4 bytes per cell, the clearance of a footprint 12 while it is
+// computed, the in-memory solvers at least 4 more for the labels and the
+// renderers 72 for the mesh, all of them whole in memory. So on an 8 GB
+// machine the out-of-core solver handles about 30k×30k cells, 20k×20k with a
+// footprint, the other solvers about 15k×15k and the renderers under 10k×10k,
+// not the 50k×50k a fully tiled pipeline would
+type TiledHeightMap struct {
+	dir       string
+	meta      tiledMeta
+	cacheSize int
+
+	mutex sync.Mutex
+	tiles map[tileKey]*list.Element
+	// lru keeps the most recently used tile in the front
+	lru *list.List
+}
+
+// CreateTiledHeightMap creates an empty tiled height map in the directory,
+// the tiles left there by an earlier map are removed. At most cacheSize tiles
+// are kept in memory at once
+func CreateTiledHeightMap(dir string, iMax, jMax, tileSize int, cellSize float32, cacheSize int) (hm *TiledHeightMap, err error) {
+	if tileSize <= 0 || cacheSize <= 0 {
+		return nil, errors.New("tile and cache sizes must be positive")
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	stale, err := filepath.Glob(filepath.Join(dir, "tile_*.bin"))
+	if err != nil {
+		return
+	}
+	for _, tilePath := range stale {
+		if err = os.Remove(tilePath); err != nil {
+			return nil, err
+		}
+	}
+	hm = newTiledHeightMap(dir, tiledMeta{Rows: iMax, Cols: jMax, TileSize: tileSize, CellSize: cellSize}, cacheSize)
+	if err = hm.writeMeta(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// OpenTiledHeightMap opens the tiled height map stored in the directory
+func OpenTiledHeightMap(dir string, cacheSize int) (hm *TiledHeightMap, err error) {
+	if cacheSize <= 0 {
+		return nil, errors.New("cache size must be positive")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, tiledMetaFile))
+	if err != nil {
+		return
+	}
+	meta := tiledMeta{}
+	if err = json.Unmarshal(data, &meta); err != nil {
+		return
+	}
+	if meta.TileSize <= 0 {
+		return nil, fmt.Errorf("incorrect tile size %d", meta.TileSize)
+	}
+	return newTiledHeightMap(dir, meta, cacheSize), nil
+}
+
+func newTiledHeightMap(dir string, meta tiledMeta, cacheSize int) (hm *TiledHeightMap) {
+	hm = new(TiledHeightMap)
+	hm.dir = dir
+	hm.meta = meta
+	hm.cacheSize = cacheSize
+	hm.tiles = make(map[tileKey]*list.Element)
+	hm.lru = list.New()
+	return
+}
+
+func (hm *TiledHeightMap) Bounds() (iMax, jMax int) {
+	return hm.meta.Rows, hm.meta.Cols
+}
+
+func (hm *TiledHeightMap) Spacing() float32 {
+	if hm.meta.CellSize <= 0 {
+		return 1
+	}
+	return hm.meta.CellSize
+}
+
+// TileSize returns the side of a tile in cells
+func (hm *TiledHeightMap) TileSize() int {
+	return hm.meta.TileSize
+}
+
+func (hm *TiledHeightMap) writeMeta() (err error) {
+	data, err := json.Marshal(hm.meta)
+	if err != nil {
+		return
+	}
+	return os.WriteFile(filepath.Join(hm.dir, tiledMetaFile), data, 0644)
+}
+
+// SetFill changes the value of the cells which have never been written. It
+// lets a whole grid be initialised without touching the disk
+func (hm *TiledHeightMap) SetFill(value float32) (err error) {
+	hm.mutex.Lock()
+	defer hm.mutex.Unlock()
+	hm.meta.Fill = value
+	return hm.writeMeta()
+}
+
+// SetGeoreference stores the origin, its degree step and the no data value, see HeightMap
+func (hm *TiledHeightMap) SetGeoreference(origin *[2]float64, degreeStep float64, noData *float32) (err error) {
+	hm.mutex.Lock()
+	defer hm.mutex.Unlock()
+	hm.meta.Origin, hm.meta.DegreeStep, hm.meta.NoData = origin, degreeStep, noData
+	return hm.writeMeta()
+}
+
+// Georeference returns the Origin and the DegreeStep, see HeightMap
+func (hm *TiledHeightMap) Georeference() (origin *[2]float64, degreeStep float64) {
+	return hm.meta.Origin, hm.meta.DegreeStep
+}
+
+func (hm *TiledHeightMap) tilePath(key tileKey) string {
+	return filepath.Join(hm.dir, fmt.Sprintf("tile_%d_%d.bin", key.I, key.J))
+}
+
+func (hm *TiledHeightMap) readTile(key tileKey) (t *tile, err error) {
+	t = &tile{key: key, heights: make([]float32, hm.meta.TileSize*hm.meta.TileSize)}
+	file, err := os.Open(hm.tilePath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		if hm.meta.Fill != 0 {
+			for idx := range t.heights {
+				t.heights[idx] = hm.meta.Fill
+			}
+		}
+		return t, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err = binary.Read(bufio.NewReader(file), binary.LittleEndian, t.heights); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (hm *TiledHeightMap) writeTile(t *tile) (err error) {
+	file, err := os.Create(hm.tilePath(t.key))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	if err = binary.Write(writer, binary.LittleEndian, t.heights); err != nil {
+		return
+	}
+	if err = writer.Flush(); err != nil {
+		return
+	}
+	t.dirty = false
+	return
+}
+
+// tile returns the tile with the cell loading it if needed. The mutex must be held
+func (hm *TiledHeightMap) tile(i, j int) (t *tile, offset int) {
+	size := hm.meta.TileSize
+	key := tileKey{i / size, j / size}
+	offset = (i%size)*size + j%size
+	if element, ok := hm.tiles[key]; ok {
+		hm.lru.MoveToFront(element)
+		return element.Value.(*tile), offset
+	}
+
+	if hm.lru.Len() >= hm.cacheSize {
+		oldest := hm.lru.Back()
+		evicted := oldest.Value.(*tile)
+		if evicted.dirty {
+			if err := hm.writeTile(evicted); err != nil {
+				log.WithError(err).Panic("failed to write the tile")
+			}
+		}
+		hm.lru.Remove(oldest)
+		delete(hm.tiles, evicted.key)
+	}
+	t, err := hm.readTile(key)
+	if err != nil {
+		log.WithError(err).Panic("failed to read the tile")
+	}
+	hm.tiles[key] = hm.lru.PushFront(t)
+	return
+}
+
+func (hm *TiledHeightMap) At(i, j int) float32 {
+	hm.mutex.Lock()
+	defer hm.mutex.Unlock()
+	t, offset := hm.tile(i, j)
+	return t.heights[offset]
+}
+
+func (hm *TiledHeightMap) SetAt(i, j int, value float32) {
+	hm.mutex.Lock()
+	defer hm.mutex.Unlock()
+	t, offset := hm.tile(i, j)
+	t.heights[offset] = value
+	t.dirty = true
+}
+
+func (hm *TiledHeightMap) IsNoData(i, j int) bool {
+	return hm.meta.NoData != nil && hm.At(i, j) == *hm.meta.NoData
+}
+
+// Flush writes all the changed tiles to the disk
+func (hm *TiledHeightMap) Flush() (err error) {
+	hm.mutex.Lock()
+	defer hm.mutex.Unlock()
+	for element := hm.lru.Front(); element != nil; element = element.Next() {
+		if t := element.Value.(*tile); t.dirty {
+			if err = hm.writeTile(t); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// CopyGrid copies the heights tile by tile, so that a tiled source or
+// destination does not thrash its cache
+func CopyGrid(dst, src Grid, tileSize int) {
+	iMax, jMax := src.Bounds()
+	for ti := 0; ti < iMax; ti += tileSize {
+		for tj := 0; tj < jMax; tj += tileSize {
+			for i := ti; i < ti+tileSize && i < iMax; i++ {
+				for j := tj; j < tj+tileSize && j < jMax; j++ {
+					dst.SetAt(i, j, src.At(i, j))
+				}
+			}
+		}
+	}
+}
+
+// InMemory returns the heights as a HeightMap, copying them if needed
+func InMemory(grid Grid) (hm *HeightMap) {
+	if hm, ok := grid.(*HeightMap); ok {
+		return hm
+	}
+	iMax, jMax := grid.Bounds()
+	hm = EmptyHeightMap(iMax, jMax)
+	hm.CellSize = grid.Spacing()
+	tileSize := iMax
+	if tiled, ok := grid.(*TiledHeightMap); ok {
+		tileSize = tiled.TileSize()
+		hm.Origin, hm.DegreeStep, hm.NoData = tiled.meta.Origin, tiled.meta.DegreeStep, tiled.meta.NoData
+	}
+	CopyGrid(hm, grid, tileSize)
+	return
+}
+
+// DefaultTileCache is the number of tiles LoadGrid keeps in memory
+const DefaultTileCache = 64
+
+// LoadGrid opens the tiled height map if the path is a directory and loads
+// the height map in any of the other formats otherwise
+func LoadGrid(path string) Grid {
+	info, err := os.Stat(path)
+	if err != nil {
+		log.WithError(err).Panic("failed to load height map from file")
+	}
+	if !info.IsDir() {
+		return LoadHeightMap(path)
+	}
+	hm, err := OpenTiledHeightMap(path, DefaultTileCache)
+	if err != nil {
+		log.WithError(err).Panic("failed to open the tiled height map")
+	}
+	return hm
+}
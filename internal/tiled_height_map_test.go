@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTiledRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	// A cache of one tile evicts on every move to another tile
+	hm, err := CreateTiledHeightMap(dir, 7, 5, 3, 30, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noData := float32(-9999)
+	if err = hm.SetGeoreference(&[2]float64{6.5, 45.25}, 0.25, &noData); err != nil {
+		t.Fatal(err)
+	}
+	want := rampHeightMap(7, 5, -3, 31)
+	CopyGrid(hm, want, 3)
+	for i := 0; i < 7; i++ {
+		for j := 0; j < 5; j++ {
+			if hm.At(i, j) != want.At(i, j) {
+				t.Fatalf("the evicted tile has %g instead of %g at (%d, %d)", hm.At(i, j), want.At(i, j), i, j)
+			}
+		}
+	}
+	if err = hm.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := OpenTiledHeightMap(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iMax, jMax := read.Bounds(); iMax != 7 || jMax != 5 || read.Spacing() != 30 || read.TileSize() != 3 {
+		t.Errorf("opened %dx%d cells of %g in tiles of %d", iMax, jMax, read.Spacing(), read.TileSize())
+	}
+	copied := InMemory(read)
+	sameHeights(t, copied, want)
+	if copied.Origin == nil || *copied.Origin != [2]float64{6.5, 45.25} || copied.NoData == nil || *copied.NoData != noData {
+		t.Errorf("opened the origin %v and no data %v", copied.Origin, copied.NoData)
+	}
+}
+
+func TestTiledFlush(t *testing.T) {
+	dir := t.TempDir()
+	hm, err := CreateTiledHeightMap(dir, 4, 4, 2, 0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hm.SetAt(3, 1, 5)
+	// Nothing is written until the flush while the tile stays in the cache
+	if _, err = os.Stat(filepath.Join(dir, "tile_1_0.bin")); err == nil {
+		t.Error("the tile is written before the flush")
+	}
+	if err = hm.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	read, err := OpenTiledHeightMap(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.At(3, 1) != 5 || read.At(2, 0) != 0 {
+		t.Errorf("read %g and %g after the flush", read.At(3, 1), read.At(2, 0))
+	}
+}
+
+func TestTiledFill(t *testing.T) {
+	dir := t.TempDir()
+	hm, err := CreateTiledHeightMap(dir, 5, 5, 2, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = hm.SetFill(-1); err != nil {
+		t.Fatal(err)
+	}
+	hm.SetAt(0, 0, 2)
+	// The written tile keeps its fill after the eviction
+	if hm.At(4, 4) != -1 || hm.At(0, 1) != -1 || hm.At(0, 0) != 2 {
+		t.Errorf("read %g, %g and %g", hm.At(4, 4), hm.At(0, 1), hm.At(0, 0))
+	}
+}
+
+func TestCreateTiledRemovesOldTiles(t *testing.T) {
+	dir := t.TempDir()
+	old, err := CreateTiledHeightMap(dir, 4, 4, 2, 0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	CopyGrid(old, rampHeightMap(4, 4, 1, 16), 2)
+	if err = old.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	hm, err := CreateTiledHeightMap(dir, 4, 4, 2, 0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			if hm.At(i, j) != 0 {
+				t.Fatalf("the new map has %g of the old one at (%d, %d)", hm.At(i, j), i, j)
+			}
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// Remap builds a map of the size taking every cell from a cell of the source.
+// The same remap applied to the height map and to its texture keeps them
+// aligned
+type Remap struct {
+	IMax, JMax int
+	Source     func(i, j int) (si, sj int)
+	// Offset is the source cell of the cell (0, 0) when the remap keeps the
+	// cells along the axes of the source, the Origin moves there. The
+	// georeference is dropped if nil as the turned cells are no longer north up
+	Offset *[2]int
+}
+
+// Heights applies the remap to the height map
+func (remap Remap) Heights(hm *HeightMap) (result *HeightMap) {
+	result = EmptyHeightMap(remap.IMax, remap.JMax)
+	result.CellSize, result.NoData = hm.CellSize, hm.NoData
+	if remap.Offset != nil && hm.Origin != nil {
+		step := hm.originStep()
+		// The easting grows with i and the northing falls with j
+		result.Origin = &[2]float64{hm.Origin[0] + float64(remap.Offset[0])*step, hm.Origin[1] - float64(remap.Offset[1])*step}
+		result.DegreeStep = hm.DegreeStep
+	}
+	ParallelFor(0, remap.IMax, 1, func(i int) {
+		for j := 0; j < remap.JMax; j++ {
+			result.SetAt(i, j, hm.At(remap.Source(i, j)))
+		}
+	})
+	return
+}
+
+// RGBA applies the remap to the texture, pixel x is i and pixel y is j
+func (remap Remap) RGBA(rgba *image.RGBA) (result *image.RGBA) {
+	result = image.NewRGBA(image.Rect(0, 0, remap.IMax, remap.JMax))
+	for i := 0; i < remap.IMax; i++ {
+		for j := 0; j < remap.JMax; j++ {
+			si, sj := remap.Source(i, j)
+			result.SetRGBA(i, j, rgba.RGBAAt(rgba.Rect.Min.X+si, rgba.Rect.Min.Y+sj))
+		}
+	}
+	return
+}
+
+// CropRemap cuts the window of the size starting at the cell (iFrom, jFrom)
+func CropRemap(iMax, jMax, iFrom, jFrom, iSize, jSize int) (remap Remap, err error) {
+	if iFrom < 0 || jFrom < 0 || iSize <= 0 || jSize <= 0 || iFrom+iSize > iMax || jFrom+jSize > jMax {
+		return remap, fmt.Errorf("the window %dx%d at (%d, %d) is out of the %dx%d map", iSize, jSize, iFrom, jFrom, iMax, jMax)
+	}
+	return Remap{IMax: iSize, JMax: jSize, Source: func(i, j int) (int, int) { return iFrom + i, jFrom + j }, Offset: &[2]int{iFrom, jFrom}}, nil
+}
+
+// FlipRemap mirrors the map, the east and the west swap if alongI
+func FlipRemap(iMax, jMax int, alongI bool) Remap {
+	if alongI {
+		return Remap{IMax: iMax, JMax: jMax, Source: func(i, j int) (int, int) { return iMax - 1 - i, j }}
+	}
+	return Remap{IMax: iMax, JMax: jMax, Source: func(i, j int) (int, int) { return i, jMax - 1 - j }}
+}
+
+// RotateRemap turns the map clockwise as it is seen on the texture by the number of quarter turns
+func RotateRemap(iMax, jMax, quarters int) Remap {
+	switch (quarters%4 + 4) % 4 {
+	case 1:
+		return Remap{IMax: jMax, JMax: iMax, Source: func(i, j int) (int, int) { return j, jMax - 1 - i }}
+	case 2:
+		return Remap{IMax: iMax, JMax: jMax, Source: func(i, j int) (int, int) { return iMax - 1 - i, jMax - 1 - j }}
+	case 3:
+		return Remap{IMax: jMax, JMax: iMax, Source: func(i, j int) (int, int) { return iMax - 1 - j, i }}
+	default:
+		return Remap{IMax: iMax, JMax: jMax, Source: func(i, j int) (int, int) { return i, j }, Offset: &[2]int{}}
+	}
+}
+
+// NearestRemap resamples the map to the size taking the closest cell, the
+// textures are always resampled so as the colours stay the classes of the profiles
+func NearestRemap(iMax, jMax, iSize, jSize int) Remap {
+	return Remap{IMax: iSize, JMax: jSize, Source: func(i, j int) (int, int) {
+		return nearest(i, iMax, iSize), nearest(j, jMax, jSize)
+	}}
+}
+
+// sourcePoint maps the centre of the cell onto the source cells
+func sourcePoint(idx, max, size int) float64 {
+	return (float64(idx)+0.5)*float64(max)/float64(size) - 0.5
+}
+
+func nearest(idx, max, size int) int {
+	return clampIndex(int(math.Round(sourcePoint(idx, max, size))), max)
+}
+
+func clampIndex(idx, max int) int {
+	if idx < 0 {
+		return 0
+	}
+	if idx >= max {
+		return max - 1
+	}
+	return idx
+}
+
+type ResampleMethod string
+
+const (
+	NearestResample  ResampleMethod = "nearest"
+	BilinearResample ResampleMethod = "bilinear"
+	// BicubicResample is the Catmull-Rom spline, it may overshoot the source range a little
+	BicubicResample ResampleMethod = "bicubic"
+)
+
+// Resample changes the resolution of the height map keeping its extent and
+// Origin. The cells stay square, so the size must keep the aspect ratio of
+// the map up to the rounding. A cell next to no data has no data
+func Resample(hm *HeightMap, iSize, jSize int, method ResampleMethod) (result *HeightMap, err error) {
+	iMax, jMax := hm.Bounds()
+	if iSize <= 0 || jSize <= 0 {
+		return nil, fmt.Errorf("incorrect size %dx%d", iSize, jSize)
+	}
+	if int(math.Round(float64(jMax*iSize)/float64(iMax))) != jSize && int(math.Round(float64(iMax*jSize)/float64(jMax))) != iSize {
+		return nil, fmt.Errorf("the size %dx%d does not keep the aspect ratio of the %dx%d map", iSize, jSize, iMax, jMax)
+	}
+	var radius int
+	var weight func(t float64) float64
+	switch method {
+	case NearestResample:
+		result = NearestRemap(iMax, jMax, iSize, jSize).Heights(hm)
+		result.CellSize, result.Origin = hm.Spacing()*float32(iMax)/float32(iSize), hm.Origin
+		result.DegreeStep = hm.DegreeStep * float64(iMax) / float64(iSize)
+		return
+	case BilinearResample:
+		radius, weight = 1, func(t float64) float64 { return 1 - math.Abs(t) }
+	case BicubicResample:
+		radius, weight = 2, catmullRom
+	default:
+		return nil, fmt.Errorf("unknown resample method %q", method)
+	}
+
+	result = EmptyHeightMap(iSize, jSize)
+	result.CellSize = hm.Spacing() * float32(iMax) / float32(iSize)
+	result.NoData, result.Origin = hm.NoData, hm.Origin
+	result.DegreeStep = hm.DegreeStep * float64(iMax) / float64(iSize)
+	ParallelFor(0, iSize, 1, func(i int) {
+		x := sourcePoint(i, iMax, iSize)
+		xFloor := math.Floor(x)
+		for j := 0; j < jSize; j++ {
+			y := sourcePoint(j, jMax, jSize)
+			yFloor := math.Floor(y)
+			sum, noData := 0., false
+		window:
+			for di := 1 - radius; di <= radius; di++ {
+				wi := weight(x - xFloor - float64(di))
+				si := clampIndex(int(xFloor)+di, iMax)
+				for dj := 1 - radius; dj <= radius; dj++ {
+					w := wi * weight(y-yFloor-float64(dj))
+					sj := clampIndex(int(yFloor)+dj, jMax)
+					if w == 0 {
+						continue
+					}
+					if hm.IsNoData(si, sj) {
+						noData = true
+						break window
+					}
+					sum += w * float64(hm.At(si, sj))
+				}
+			}
+			if noData {
+				result.SetAt(i, j, *hm.NoData)
+				continue
+			}
+			result.SetAt(i, j, float32(sum))
+		}
+	})
+	return
+}
+
+func catmullRom(t float64) float64 {
+	t = math.Abs(t)
+	switch {
+	case t < 1:
+		return 1.5*t*t*t - 2.5*t*t + 1
+	case t < 2:
+		return -0.5*t*t*t + 2.5*t*t - 4*t + 2
+	default:
+		return 0
+	}
+}
+
+// MosaicLayout places the pieces given row by row, columns pieces per row
+// along i. The neighbour pieces share overlap cells. All the pieces of a
+// column must have the same width and all the pieces of a row the same height
+func MosaicLayout(sizes [][2]int, columns, overlap int) (offsets [][2]int, iMax, jMax int, err error) {
+	if len(sizes) == 0 || columns <= 0 || len(sizes)%columns != 0 {
+		return nil, 0, 0, fmt.Errorf("%d pieces do not make rows of %d", len(sizes), columns)
+	}
+	if overlap < 0 {
+		return nil, 0, 0, fmt.Errorf("the overlap %d is negative", overlap)
+	}
+	rows := len(sizes) / columns
+	offsets = make([][2]int, len(sizes))
+	iOffsets, jOffsets := make([]int, columns+1), make([]int, rows+1)
+	for column := 0; column < columns; column++ {
+		iOffsets[column+1] = iOffsets[column] + sizes[column][0] - overlap
+	}
+	for row := 0; row < rows; row++ {
+		jOffsets[row+1] = jOffsets[row] + sizes[row*columns][1] - overlap
+	}
+	for idx, size := range sizes {
+		row, column := idx/columns, idx%columns
+		if size[0] != sizes[column][0] || size[1] != sizes[row*columns][1] {
+			return nil, 0, 0, fmt.Errorf("the piece %d is %dx%d, it does not fit its row and column", idx, size[0], size[1])
+		}
+		if size[0] <= overlap || size[1] <= overlap {
+			return nil, 0, 0, fmt.Errorf("the piece %d is not larger than the overlap", idx)
+		}
+		offsets[idx] = [2]int{iOffsets[column], jOffsets[row]}
+	}
+	return offsets, iOffsets[columns] + overlap, jOffsets[rows] + overlap, nil
+}
+
+// Stitch joins the height maps into a mosaic, see MosaicLayout. The later
+// pieces overwrite the overlap of the earlier ones. The mosaic takes the
+// Origin of the first piece
+func Stitch(pieces []*HeightMap, columns, overlap int) (result *HeightMap, err error) {
+	sizes := make([][2]int, len(pieces))
+	for idx, piece := range pieces {
+		sizes[idx][0], sizes[idx][1] = piece.Bounds()
+	}
+	offsets, iMax, jMax, err := MosaicLayout(sizes, columns, overlap)
+	if err != nil {
+		return
+	}
+	result = EmptyHeightMap(iMax, jMax)
+	result.CellSize, result.Origin, result.DegreeStep = pieces[0].CellSize, pieces[0].Origin, pieces[0].DegreeStep
+	for _, piece := range pieces {
+		if piece.NoData != nil {
+			result.NoData = piece.NoData
+			break
+		}
+	}
+	for idx, piece := range pieces {
+		if piece.Spacing() != result.Spacing() {
+			return nil, fmt.Errorf("the piece %d has another cell size", idx)
+		}
+		for i := 0; i < sizes[idx][0]; i++ {
+			for j := 0; j < sizes[idx][1]; j++ {
+				height := piece.At(i, j)
+				if piece.IsNoData(i, j) && result.NoData != nil {
+					height = *result.NoData
+				}
+				result.SetAt(offsets[idx][0]+i, offsets[idx][1]+j, height)
+			}
+		}
+	}
+	return
+}
+
+// StitchRGBA joins the textures as Stitch joins their height maps
+func StitchRGBA(pieces []*image.RGBA, columns, overlap int) (result *image.RGBA, err error) {
+	sizes := make([][2]int, len(pieces))
+	for idx, piece := range pieces {
+		sizes[idx] = [2]int{piece.Rect.Dx(), piece.Rect.Dy()}
+	}
+	offsets, iMax, jMax, err := MosaicLayout(sizes, columns, overlap)
+	if err != nil {
+		return
+	}
+	result = image.NewRGBA(image.Rect(0, 0, iMax, jMax))
+	for idx, piece := range pieces {
+		for i := 0; i < sizes[idx][0]; i++ {
+			for j := 0; j < sizes[idx][1]; j++ {
+				result.SetRGBA(offsets[idx][0]+i, offsets[idx][1]+j, piece.RGBAAt(piece.Rect.Min.X+i, piece.Rect.Min.Y+j))
+			}
+		}
+	}
+	return
+}
+
+// Rescale multiplies the measured heights by the factor and adds the offset
+func (hm *HeightMap) Rescale(factor, offset float32) {
+	for idx, height := range hm.Heights {
+		if hm.NoData != nil && height == *hm.NoData {
+			continue
+		}
+		hm.Heights[idx] = height*factor + offset
+	}
+}
+
+// Normalise stretches the measured heights onto the range, a flat map goes to its bottom
+func (hm *HeightMap) Normalise(min, max float32) {
+	low, high, ok := hm.heightRange()
+	if !ok {
+		return
+	}
+	factor := float32(0)
+	if high > low {
+		factor = (max - min) / (high - low)
+	}
+	hm.Rescale(factor, min-low*factor)
+}
@@ -0,0 +1,153 @@
+package internal
+
+import "testing"
+
+func sameHeights(t *testing.T, got, want *HeightMap) {
+	t.Helper()
+	iMax, jMax := want.Bounds()
+	if iGot, jGot := got.Bounds(); iGot != iMax || jGot != jMax {
+		t.Fatalf("got %dx%d cells instead of %dx%d", iGot, jGot, iMax, jMax)
+	}
+	for i := 0; i < iMax; i++ {
+		for j := 0; j < jMax; j++ {
+			if got.At(i, j) != want.At(i, j) {
+				t.Fatalf("(%d, %d) is %g instead of %g", i, j, got.At(i, j), want.At(i, j))
+			}
+		}
+	}
+}
+
+func TestRemapRoundTrips(t *testing.T) {
+	hm := rampHeightMap(5, 3, 0, 14)
+	cases := []struct {
+		name  string
+		there func(iMax, jMax int) Remap
+		back  func(iMax, jMax int) Remap
+	}{
+		{"flip i", func(iMax, jMax int) Remap { return FlipRemap(iMax, jMax, true) }, func(iMax, jMax int) Remap { return FlipRemap(iMax, jMax, true) }},
+		{"flip j", func(iMax, jMax int) Remap { return FlipRemap(iMax, jMax, false) }, func(iMax, jMax int) Remap { return FlipRemap(iMax, jMax, false) }},
+		{"rotate quarter", func(iMax, jMax int) Remap { return RotateRemap(iMax, jMax, 1) }, func(iMax, jMax int) Remap { return RotateRemap(iMax, jMax, -1) }},
+		{"rotate half", func(iMax, jMax int) Remap { return RotateRemap(iMax, jMax, 2) }, func(iMax, jMax int) Remap { return RotateRemap(iMax, jMax, 6) }},
+		{"rotate three quarters", func(iMax, jMax int) Remap { return RotateRemap(iMax, jMax, 3) }, func(iMax, jMax int) Remap { return RotateRemap(iMax, jMax, 1) }},
+		{"nearest same size", func(iMax, jMax int) Remap { return NearestRemap(iMax, jMax, iMax, jMax) }, func(iMax, jMax int) Remap { return RotateRemap(iMax, jMax, 0) }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			turned := c.there(hm.Bounds()).Heights(hm)
+			sameHeights(t, c.back(turned.Bounds()).Heights(turned), hm)
+		})
+	}
+}
+
+func TestRotateQuarterClockwise(t *testing.T) {
+	hm := rampHeightMap(3, 2, 0, 5)
+	turned := RotateRemap(3, 2, 1).Heights(hm)
+	// The north west corner goes to the north east as seen on the texture
+	if iMax, jMax := turned.Bounds(); iMax != 2 || jMax != 3 {
+		t.Fatalf("got %dx%d cells instead of 2x3", iMax, jMax)
+	}
+	if turned.At(1, 0) != hm.At(0, 0) || turned.At(0, 0) != hm.At(0, 1) || turned.At(0, 2) != hm.At(2, 1) {
+		t.Errorf("the cells went elsewhere: %v from %v", turned.Heights, hm.Heights)
+	}
+}
+
+func TestCropOrigin(t *testing.T) {
+	hm := rampHeightMap(6, 4, 0, 23)
+	hm.CellSize = 30
+	hm.Origin = &[2]float64{1000, 5000}
+	remap, err := CropRemap(6, 4, 2, 1, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cropped := remap.Heights(hm)
+	if cropped.At(0, 0) != hm.At(2, 1) || cropped.At(2, 1) != hm.At(4, 2) {
+		t.Errorf("the window is elsewhere: %v", cropped.Heights)
+	}
+	if cropped.Origin == nil || *cropped.Origin != [2]float64{1060, 4970} {
+		t.Errorf("the origin is %v instead of [1060 4970]", cropped.Origin)
+	}
+	if turned := RotateRemap(6, 4, 1).Heights(hm); turned.Origin != nil {
+		t.Errorf("the turned map kept the origin %v", *turned.Origin)
+	}
+	if _, err = CropRemap(6, 4, 4, 0, 3, 2); err == nil {
+		t.Error("the window out of the map is cropped")
+	}
+}
+
+func TestResample(t *testing.T) {
+	hm := rampHeightMap(8, 4, 0, 31)
+	hm.Origin = &[2]float64{10, 20}
+	for _, method := range []ResampleMethod{NearestResample, BilinearResample, BicubicResample} {
+		t.Run(string(method), func(t *testing.T) {
+			same, err := Resample(hm, 8, 4, method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sameHeights(t, same, hm)
+			half, err := Resample(hm, 4, 2, method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if half.Spacing() != 2 || half.Origin != hm.Origin {
+				t.Errorf("the half has the cell size %g and the origin %v", half.Spacing(), half.Origin)
+			}
+			if _, err = Resample(hm, 4, 4, method); err == nil {
+				t.Error("the cells are resampled into rectangles")
+			}
+		})
+	}
+}
+
+func TestStitchCrops(t *testing.T) {
+	hm := rampHeightMap(7, 5, 0, 34)
+	hm.Origin = &[2]float64{0, 0}
+	cases := []struct {
+		name             string
+		columns, overlap int
+		windows          [][4]int
+	}{
+		{"quadrants", 2, 0, [][4]int{{0, 0, 4, 2}, {4, 0, 3, 2}, {0, 2, 4, 3}, {4, 2, 3, 3}}},
+		{"repeated edges", 2, 1, [][4]int{{0, 0, 4, 3}, {3, 0, 4, 3}, {0, 2, 4, 3}, {3, 2, 4, 3}}},
+		{"row", 3, 0, [][4]int{{0, 0, 2, 5}, {2, 0, 2, 5}, {4, 0, 3, 5}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pieces := make([]*HeightMap, len(c.windows))
+			for idx, window := range c.windows {
+				remap, err := CropRemap(7, 5, window[0], window[1], window[2], window[3])
+				if err != nil {
+					t.Fatal(err)
+				}
+				pieces[idx] = remap.Heights(hm)
+			}
+			stitched, err := Stitch(pieces, c.columns, c.overlap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sameHeights(t, stitched, hm)
+			if stitched.Origin == nil || *stitched.Origin != *hm.Origin {
+				t.Errorf("the origin is %v instead of the first piece's", stitched.Origin)
+			}
+		})
+	}
+}
+
+func TestMosaicLayoutRejects(t *testing.T) {
+	cases := []struct {
+		name             string
+		sizes            [][2]int
+		columns, overlap int
+	}{
+		{"negative overlap", [][2]int{{3, 3}, {3, 3}}, 2, -1},
+		{"overlap of the piece", [][2]int{{3, 3}, {3, 3}}, 2, 3},
+		{"short row", [][2]int{{3, 3}, {3, 3}, {3, 3}}, 2, 0},
+		{"other width", [][2]int{{3, 3}, {3, 3}, {4, 3}, {3, 3}}, 2, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, _, _, err := MosaicLayout(c.sizes, c.columns, c.overlap); err == nil {
+				t.Error("laid out the pieces")
+			}
+		})
+	}
+}